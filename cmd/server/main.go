@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,61 +12,144 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/BaikalMine/em-subscription-service/internal/aggregates"
+	"github.com/BaikalMine/em-subscription-service/internal/budgets"
+	"github.com/BaikalMine/em-subscription-service/internal/cache"
 	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/BaikalMine/em-subscription-service/internal/database"
 	"github.com/BaikalMine/em-subscription-service/internal/handlers"
+	"github.com/BaikalMine/em-subscription-service/internal/health"
+	"github.com/BaikalMine/em-subscription-service/internal/idempotency"
+	"github.com/BaikalMine/em-subscription-service/internal/logging"
+	"github.com/BaikalMine/em-subscription-service/internal/metrics"
+	"github.com/BaikalMine/em-subscription-service/internal/notify"
+	"github.com/BaikalMine/em-subscription-service/internal/outbox"
+	"github.com/BaikalMine/em-subscription-service/internal/reminders"
 	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/BaikalMine/em-subscription-service/internal/tracing"
+	"github.com/BaikalMine/em-subscription-service/internal/webhooks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
 
+// idempotencyCleanupInterval задаёт, как часто удаляются истёкшие ключи идемпотентности.
+const idempotencyCleanupInterval = time.Hour
+
+// readinessTimeout ограничивает проверки /readyz.
+const readinessTimeout = 2 * time.Second
+
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
 		panic(fmt.Errorf("load config: %w", err))
 	}
 
-	logger := logrus.New()
-	level, err := logrus.ParseLevel(cfg.LogLevel)
-	if err != nil {
-		level = logrus.InfoLevel
-	}
-	logger.SetLevel(level)
-	logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	logger := logging.New(cfg)
 
-	db, err := sql.Open("postgres", cfg.DSN())
-	if err != nil {
-		logger.WithError(err).Fatal("failed to connect to database")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if cfg.DBDriver == config.DriverMemory {
+			logger.Fatalf("migrations require DB_DRIVER=%s or %s", config.DriverPostgres, config.DriverSQLite)
+		}
+		db, dialect, err := database.Open(context.Background(), cfg)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to connect to database")
+		}
+		defer db.Close()
+		if err := runMigrate(context.Background(), db, dialect, logger, os.Args[2:]); err != nil {
+			logger.WithError(err).Fatal("migration failed")
+		}
+		return
 	}
-	defer db.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := db.PingContext(ctx); err != nil {
-		logger.WithError(err).Fatal("failed to ping database")
-	}
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	store := storage.NewStore(db)
-	subHandlers := handlers.NewHandler(store, logger)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to set up tracing")
+	}
+	registry := metrics.New()
 
 	// router создаётся, подключаются middleware и маршруты.
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
-	router.Use(requestLogger(logger))
-	router.Use(middleware.Timeout(60 * time.Second))
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware(logger))
+	router.Use(registry.Middleware)
+	router.Use(middleware.Timeout(cfg.RequestTimeout))
+	router.NotFound(handlers.NotFound)
+	router.MethodNotAllowed(handlers.MethodNotAllowed)
+
+	probe := health.NewProbe(readinessTimeout)
+	var repo storage.SubscriptionRepository
+	var keys idempotency.Store
+	switch cfg.DBDriver {
+	case config.DriverMemory:
+		memory := storage.NewMemoryStore()
+		memory.RejectOverlaps(cfg.OverlapMode == config.OverlapReject)
+		repo = tracing.Repository(memory)
+		keys = idempotency.NewMemoryStore()
+		go idempotency.Cleanup(signalCtx, keys, idempotencyCleanupInterval, logger)
+		logger.Warn("using in-memory storage: data is lost on restart; webhooks, budgets, outbox relay and reminders are disabled")
+	default:
+		db, dialect, err := database.Open(context.Background(), cfg)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to connect to database")
+		}
+		defer db.Close()
+		registry.RegisterDB(db)
+
+		migrator, err := newMigrator(db, dialect)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to load migrations")
+		}
+		if cfg.MigrateOnStart {
+			if err := migrateUp(context.Background(), migrator, logger); err != nil {
+				logger.WithError(err).Fatal("failed to apply migrations")
+			}
+		}
+
+		probe.Add("database", health.Database(db))
+		probe.Add("migrations", health.Migrations(migrator))
+
+		store := storage.NewStore(db, dialect)
+		store.RejectOverlaps(cfg.OverlapMode == config.OverlapReject)
+		if cfg.AggregatesEnabled {
+			// Запас в один интервал покрывает сам пересчёт и одну неудачную попытку.
+			store.UseAggregates(2 * cfg.AggregateRefreshInterval)
+		}
+		repo = tracing.Repository(store)
+		keys = idempotency.NewSQLStore(db, dialect)
+		go idempotency.Cleanup(signalCtx, keys, idempotencyCleanupInterval, logger)
+		startBackground(signalCtx, cfg, db, dialect, store, router, logger)
+	}
 
-	subHandlers.RegisterRoutes(router)
+	repo = withSummaryCache(cfg, registry.Repository(repo), registry, logger)
+	go registry.RegisterBusiness(repo, logger, cfg.BusinessMetricsInterval, cfg.AggregatesEnabled).Run(signalCtx)
 
+	handlers.NewHandler(repo, keys, handlers.HandlerConfig{
+		IdempotencyTTL: cfg.IdempotencyTTL,
+		OverlapMode:    cfg.OverlapMode,
+	}).RegisterRoutes(router)
+
+	handlers.NewHealthHandler(probe).RegisterRoutes(router)
+	router.Handle("/debug/vars", expvar.Handler())
+	router.Handle("/metrics", registry.Handler())
 	router.Get("/swagger.yaml", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "docs/swagger.yaml")
 	})
 	router.Handle("/docs/*", http.StripPrefix("/docs/", http.FileServer(http.Dir("docs"))))
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", cfg.ServerPort),
-		Handler: router,
+		Addr:              fmt.Sprintf(":%s", cfg.ServerPort),
+		Handler:           router,
+		ReadTimeout:       cfg.ServerReadTimeout,
+		ReadHeaderTimeout: cfg.ServerReadHeaderTimeout,
+		WriteTimeout:      cfg.ServerWriteTimeout,
+		IdleTimeout:       cfg.ServerIdleTimeout,
 	}
 
 	logger.WithField("addr", server.Addr).Info("subscription service starting")
@@ -76,34 +160,114 @@ func main() {
 		}
 	}()
 
-	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
 	<-signalCtx.Done()
 	logger.Info("shutting down subscription service")
+	// Сначала /readyz начинает отвечать 503, и балансировщик успевает убрать
+	// экземпляр, а уже потом сервер перестаёт принимать соединения.
+	probe.Shutdown()
+	time.Sleep(cfg.ShutdownDrainDelay)
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer shutdownCancel()
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.WithError(err).Error("graceful shutdown failed")
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.WithError(err).Error("failed to flush traces")
+	}
 }
 
-func requestLogger(logger *logrus.Logger) func(http.Handler) http.Handler {
-	// Встраиваем логирование для каждого запроса.
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-			next.ServeHTTP(ww, r)
-			logger.WithFields(logrus.Fields{
-				"method":      r.Method,
-				"path":        r.URL.Path,
-				"status":      ww.Status(),
-				"duration_ms": time.Since(start).Milliseconds(),
-				"request_id":  middleware.GetReqID(r.Context()),
-			}).Info("request served")
+// startBackground запускает фоновые процессы, которым нужна база
+// (ретранслятор outbox, напоминания, оповещения о бюджетах, пересчёт
+// агрегатов, а в Postgres ещё и доставка вебхуков), и их маршруты.
+func startBackground(ctx context.Context, cfg *config.Config, db *sql.DB, dialect database.Dialect, store *storage.Store, router chi.Router, logger *logrus.Logger) {
+	sinks := outbox.Fanout{outbox.NewLogSink(logger)}
+	if dialect == database.Postgres {
+		webhookStore := webhooks.NewStore(db)
+		handlers.NewWebhookHandler(webhookStore).RegisterRoutes(router)
+
+		dispatcher := webhooks.NewDispatcher(webhookStore, logger, webhooks.DispatcherConfig{
+			PollInterval: cfg.WebhookPollInterval,
+			Timeout:      cfg.WebhookTimeout,
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			BaseBackoff:  cfg.WebhookBackoff,
 		})
+		go dispatcher.Run(ctx)
+		sinks = append(sinks, webhookStore)
+	} else {
+		logger.Warn("webhooks are available only with DB_DRIVER=postgres")
+	}
+
+	relay := outbox.NewRelay(db, dialect, sinks, logger, outbox.RelayConfig{
+		PollInterval: cfg.OutboxPollInterval,
+		Retention:    cfg.OutboxRetention,
+	})
+	go relay.Run(ctx)
+
+	budgetStore := budgets.NewStore(db, dialect)
+	handlers.NewBudgetHandler(budgetStore, store).RegisterRoutes(router)
+
+	notifier := notify.Retry(buildNotifier(cfg, logger), cfg.NotifyRetries, time.Second)
+	if cfg.BudgetAlertsEnabled {
+		monitor := budgets.NewMonitor(budgetStore, store, notifier, logger, cfg.BudgetCheckInterval)
+		go monitor.Run(ctx)
+		logger.WithField("interval", cfg.BudgetCheckInterval).Info("budget alerts enabled")
 	}
 
+	if cfg.AggregatesEnabled {
+		refresher := aggregates.NewRefresher(store, logger, cfg.AggregateRefreshInterval,
+			cfg.AggregateMonthsBack, cfg.AggregateMonthsAhead)
+		go refresher.Run(ctx)
+		logger.WithField("interval", cfg.AggregateRefreshInterval).Info("aggregate refresh enabled")
+	}
+
+	if cfg.RemindersEnabled {
+		scheduler := reminders.NewScheduler(store, reminders.NewJournal(db, dialect), notifier, logger,
+			cfg.ReminderInterval, cfg.ReminderLeadTime)
+		go scheduler.Run(ctx)
+		logger.WithField("lead_time", cfg.ReminderLeadTime).Info("renewal reminders enabled")
+	}
+}
+
+// withSummaryCache оборачивает репозиторий кэшем summary, выбранным в
+// конфигурации, и публикует его счётчики в /debug/vars и /metrics.
+func withSummaryCache(cfg *config.Config, repo storage.SubscriptionRepository, registry *metrics.Metrics, logger *logrus.Logger) storage.SubscriptionRepository {
+	var backend cache.Backend
+	switch cfg.SummaryCache {
+	case config.CacheMemory:
+		backend = cache.NewLRU(cfg.SummaryCacheSize)
+	case config.CacheRedis:
+		backend = cache.NewRedis(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), "subscriptions:")
+	default:
+		return repo
+	}
+
+	cached := cache.NewSummaryCache(repo, backend, cfg.SummaryCacheTTL, logger)
+	expvar.Publish("summary_cache", expvar.Func(func() any { return cached.Stats() }))
+	registry.RegisterCache(cached.Stats)
+	logger.WithFields(logrus.Fields{"backend": cfg.SummaryCache, "ttl": cfg.SummaryCacheTTL}).Info("summary cache enabled")
+	return cached
+}
+
+// buildNotifier создаёт канал уведомлений, выбранный в конфигурации.
+func buildNotifier(cfg *config.Config, logger *logrus.Logger) notify.Notifier {
+	switch cfg.Notifier {
+	case "webhook":
+		return notify.NewWebhookNotifier(cfg.NotifyWebhookURL)
+	case "smtp":
+		return notify.NewSMTPNotifier(notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPTo,
+		})
+	default:
+		return notify.NewLogNotifier(logger)
+	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/migrate"
+	"github.com/BaikalMine/em-subscription-service/migrations"
+	"github.com/sirupsen/logrus"
+)
+
+// runMigrate выполняет подкоманду migrate: up (по умолчанию), down [N] или status.
+func runMigrate(ctx context.Context, db *sql.DB, dialect database.Dialect, logger *logrus.Logger, args []string) error {
+	migrator, err := newMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		return migrateUp(ctx, migrator, logger)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("down expects a positive number of steps, got %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, mig := range reverted {
+			logger.WithFields(logrus.Fields{"version": mig.Version, "name": mig.Name}).Info("migration reverted")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %q (expected up, down or status)", action)
+	}
+}
+
+// newMigrator загружает миграции для диалекта базы.
+func newMigrator(db *sql.DB, dialect database.Dialect) (*migrate.Migrator, error) {
+	source, err := migrations.For(string(dialect))
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := migrate.New(db, dialect, source)
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: %w", err)
+	}
+	return migrator, nil
+}
+
+// migrateUp применяет все ожидающие миграции и логирует каждую.
+func migrateUp(ctx context.Context, migrator *migrate.Migrator, logger *logrus.Logger) error {
+	applied, err := migrator.Up(ctx)
+	for _, mig := range applied {
+		logger.WithFields(logrus.Fields{"version": mig.Version, "name": mig.Name}).Info("migration applied")
+	}
+	return err
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/aggregates"
+	"github.com/BaikalMine/em-subscription-service/internal/migrate"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/BaikalMine/em-subscription-service/migrations"
+	"github.com/google/uuid"
+)
+
+// csvHeader — колонки CSV импорта и экспорта. Списки promotions,
+// price_changes, members и pauses хранятся в ячейках как JSON-массивы в
+// формате API; пустая ячейка означает пустой список.
+var csvHeader = []string{
+	"id", "service_name", "category", "price", "user_id", "start_date", "end_date",
+	"trial_months", "billing_months", "promotions", "price_changes", "members", "pauses",
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	user := fs.String("user", "", "filter by user UUID")
+	service := fs.String("service", "", "filter by service name (case-insensitive)")
+	limit := fs.Int("limit", 0, "maximum number of rows (0 means no limit)")
+	offset := fs.Int("offset", 0, "number of rows to skip")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+
+	filter := storage.ListFilter{Limit: *limit, Offset: *offset}
+	if *user != "" {
+		uid, err := uuid.Parse(*user)
+		if err != nil {
+			return fmt.Errorf("invalid -user: %w", err)
+		}
+		filter.UserID = &uid
+	}
+	if *service != "" {
+		filter.ServiceName = service
+	}
+
+	subs, err := a.store.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printRecords(os.Stdout, *output, subs)
+}
+
+func runCreate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	var rec record
+	var end string
+	fs.StringVar(&rec.ServiceName, "service", "", "service name (required)")
+	fs.IntVar(&rec.Price, "price", 0, "monthly price in rubles")
+	fs.StringVar(&rec.UserID, "user", "", "user UUID (required)")
+	fs.StringVar(&rec.StartDate, "start", "", "start month MM-YYYY (required)")
+	fs.StringVar(&end, "end", "", "optional end month MM-YYYY")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+	if end != "" {
+		rec.EndDate = &end
+	}
+
+	sub, _, err := rec.toStorage()
+	if err != nil {
+		return err
+	}
+	if err := a.store.Create(ctx, sub); err != nil {
+		return err
+	}
+	return printRecords(os.Stdout, *output, []storage.Subscription{*sub})
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("f", "-", "input file (- for stdin)")
+	format := fs.String("format", "json", "input format: json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var records []record
+	var err error
+	switch *format {
+	case "json":
+		dec := json.NewDecoder(in)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&records)
+	case "csv":
+		records, err = readCSV(in)
+	default:
+		err = fmt.Errorf("unknown format %q (expected json or csv)", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	for i, rec := range records {
+		sub, pauses, err := rec.toStorage()
+		if err != nil {
+			return fmt.Errorf("record %d: %w (imported %d)", i+1, err, imported)
+		}
+		// Приостановки пишутся в одной транзакции с подпиской, поэтому
+		// неверная приостановка не оставляет подписку импортированной наполовину.
+		sub.Pauses = pauses
+		if err := a.store.Create(ctx, sub); err != nil {
+			return fmt.Errorf("record %d: %w (imported %d)", i+1, err, imported)
+		}
+		imported++
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d subscriptions\n", imported)
+	return nil
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("f", "-", "output file (- for stdout)")
+	format := fs.String("format", "json", "output format: json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+
+	subs, err := a.store.List(ctx, storage.ListFilter{})
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *format {
+	case "json":
+		records := make([]record, 0, len(subs))
+		for i := range subs {
+			records = append(records, newRecord(&subs[i]))
+		}
+		return writeJSON(out, records)
+	case "csv":
+		return writeCSV(out, subs)
+	default:
+		return fmt.Errorf("unknown format %q (expected json or csv)", *format)
+	}
+}
+
+func runSummary(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	start := fs.String("start", "", "first month MM-YYYY (required)")
+	end := fs.String("end", "", "last month MM-YYYY (required)")
+	user := fs.String("user", "", "filter by user UUID")
+	service := fs.String("service", "", "filter by service name (case-insensitive)")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+	if *start == "" || *end == "" {
+		return errors.New("-start and -end are required")
+	}
+
+	periodStart, err := parseMonth(*start)
+	if err != nil {
+		return err
+	}
+	periodEnd, err := parseMonth(*end)
+	if err != nil {
+		return err
+	}
+	if periodEnd.Before(periodStart) {
+		return errors.New("end must not be before start")
+	}
+
+	filter := storage.SummaryFilter{
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd.AddDate(0, 1, -1),
+	}
+	if *user != "" {
+		uid, err := uuid.Parse(*user)
+		if err != nil {
+			return fmt.Errorf("invalid -user: %w", err)
+		}
+		filter.UserID = &uid
+	}
+	if *service != "" {
+		filter.ServiceName = service
+	}
+
+	total, err := a.store.Summary(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return printValue(os.Stdout, *output, []string{"start", "end", "total_price"}, map[string]any{
+		"start":       *start,
+		"end":         *end,
+		"total_price": total,
+	})
+}
+
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+
+	source, err := migrations.For(string(a.dialect))
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(a.db, a.dialect, source)
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+
+	action := "up"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+
+	var changed []migrate.Migration
+	switch action {
+	case "up":
+		changed, err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			steps, err = strconv.Atoi(fs.Arg(1))
+			if err != nil || steps < 1 {
+				return fmt.Errorf("down expects a positive number of steps, got %q", fs.Arg(1))
+			}
+		}
+		changed, err = migrator.Down(ctx, steps)
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate action %q (expected up, down or status)", action)
+	}
+	for _, mig := range changed {
+		fmt.Fprintf(os.Stderr, "%s %04d_%s\n", action, mig.Version, mig.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if *output == "json" {
+		return writeJSON(os.Stdout, statuses)
+	}
+	if *output != "table" {
+		return fmt.Errorf("unknown output %q (expected table or json)", *output)
+	}
+	for _, st := range statuses {
+		state := "pending"
+		if st.Applied {
+			state = "applied " + st.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+	}
+	return nil
+}
+
+func runPurge(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 30*24*time.Hour, "purge rows deleted earlier than this long ago")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return errors.New("-older-than must not be negative")
+	}
+
+	before := time.Now().Add(-*olderThan)
+	purged, err := a.store.Purge(ctx, before)
+	if err != nil {
+		return err
+	}
+	return printValue(os.Stdout, *output, []string{"deleted_before", "purged"}, map[string]any{
+		"deleted_before": before.UTC().Format(time.RFC3339),
+		"purged":         purged,
+	})
+}
+
+func runAggregates(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("aggregates", flag.ContinueOnError)
+	back := fs.Int("months-back", a.cfg.AggregateMonthsBack, "months before the current one to aggregate")
+	ahead := fs.Int("months-ahead", a.cfg.AggregateMonthsAhead, "months after the current one to aggregate")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.connect(ctx); err != nil {
+		return err
+	}
+	if *back < 0 || *ahead < 0 {
+		return errors.New("-months-back and -months-ahead must not be negative")
+	}
+
+	from, to := aggregates.Window(time.Now(), *back, *ahead)
+	if err := a.store.RefreshAggregates(ctx, from, to); err != nil {
+		return err
+	}
+	return printValue(os.Stdout, *output, []string{"from", "to"}, map[string]any{
+		"from": from.Format("01-2006"),
+		"to":   to.Format("01-2006"),
+	})
+}
+
+// readCSV разбирает CSV с заголовком из csvHeader (порядок колонок
+// произвольный, необязательные колонки можно опустить).
+func readCSV(in io.Reader) ([]record, error) {
+	reader := csv.NewReader(in)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"service_name", "price", "user_id", "start_date"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("csv header misses column %q", required)
+		}
+	}
+
+	column := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []record
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := record{ID: column(row, "id")}
+		rec.ServiceName = column(row, "service_name")
+		rec.UserID = column(row, "user_id")
+		rec.StartDate = column(row, "start_date")
+		if category := column(row, "category"); category != "" {
+			rec.Category = &category
+		}
+		if end := column(row, "end_date"); end != "" {
+			rec.EndDate = &end
+		}
+		if rec.Price, err = strconv.Atoi(column(row, "price")); err != nil {
+			return nil, fmt.Errorf("line %d: price must be an integer", line)
+		}
+		for _, field := range []struct {
+			name string
+			dest *int
+		}{{"trial_months", &rec.TrialMonths}, {"billing_months", &rec.BillingMonths}} {
+			if value := column(row, field.name); value != "" {
+				if *field.dest, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("line %d: %s must be an integer", line, field.name)
+				}
+			}
+		}
+		for _, field := range []struct {
+			name string
+			dest any
+		}{{"promotions", &rec.Promotions}, {"price_changes", &rec.PriceChanges}, {"members", &rec.Members}, {"pauses", &rec.Pauses}} {
+			if value := column(row, field.name); value != "" {
+				if err := json.Unmarshal([]byte(value), field.dest); err != nil {
+					return nil, fmt.Errorf("line %d: %s must be a JSON array: %w", line, field.name, err)
+				}
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// writeCSV выгружает подписки в CSV с заголовком csvHeader.
+func writeCSV(out io.Writer, subs []storage.Subscription) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for i := range subs {
+		rec := newRecord(&subs[i])
+		lists := make([]string, 0, 4)
+		for _, list := range []any{rec.Promotions, rec.PriceChanges, rec.Members, rec.Pauses} {
+			value, err := jsonCell(list)
+			if err != nil {
+				return err
+			}
+			lists = append(lists, value)
+		}
+		if err := writer.Write(append([]string{
+			rec.ID, rec.ServiceName, deref(rec.Category), strconv.Itoa(rec.Price), rec.UserID, rec.StartDate,
+			deref(rec.EndDate), strconv.Itoa(rec.TrialMonths), strconv.Itoa(rec.BillingMonths),
+		}, lists...)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// jsonCell кодирует список для ячейки CSV; пустой список даёт пустую ячейку.
+func jsonCell(list any) (string, error) {
+	value, err := json.Marshal(list)
+	if err != nil || string(value) == "null" {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// deref возвращает значение строки или пустую строку для nil.
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
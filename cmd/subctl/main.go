@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+)
+
+const usage = `subctl — администрирование сервиса подписок
+
+Usage:
+  subctl <command> [flags]
+
+Commands:
+  list        список подписок
+  create      создать подписку
+  import      загрузить подписки из JSON или CSV
+  export      выгрузить подписки в JSON или CSV
+  summary     пересчитать суммарную стоимость за период
+  migrate     применить, откатить или показать миграции (up|down N|status)
+  purge       физически удалить помеченные удалёнными подписки
+  aggregates  пересчитать помесячные агрегаты для summary и аналитики
+
+Run "subctl <command> -h" for command flags.
+`
+
+// app хранит зависимости, общие для всех команд. Подключение к базе
+// открывается лениво, чтобы -h работал без доступной базы.
+type app struct {
+	cfg     *config.Config
+	db      *sql.DB
+	dialect database.Dialect
+	store   *storage.Store
+}
+
+// command описывает подкоманду CLI.
+type command func(ctx context.Context, a *app, args []string) error
+
+var commands = map[string]command{
+	"list":       runList,
+	"create":     runCreate,
+	"import":     runImport,
+	"export":     runExport,
+	"summary":    runSummary,
+	"migrate":    runMigrate,
+	"purge":      runPurge,
+	"aggregates": runAggregates,
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cmd, os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "subctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// run загружает конфигурацию и выполняет команду.
+func run(ctx context.Context, cmd command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	a := &app{cfg: cfg}
+	defer a.close()
+	return cmd(ctx, a, args)
+}
+
+// connect открывает подключение к базе при первом обращении.
+func (a *app) connect(ctx context.Context) error {
+	if a.db != nil {
+		return nil
+	}
+	if a.cfg.DBDriver == config.DriverMemory {
+		return fmt.Errorf("subctl requires DB_DRIVER=%s or %s, got %q",
+			config.DriverPostgres, config.DriverSQLite, a.cfg.DBDriver)
+	}
+	db, dialect, err := database.Open(ctx, a.cfg)
+	if err != nil {
+		return err
+	}
+	a.db = db
+	a.dialect = dialect
+	a.store = storage.NewStore(db, dialect)
+	// create и import подчиняются тому же OVERLAP_MODE, что и API.
+	a.store.RejectOverlaps(a.cfg.OverlapMode == config.OverlapReject)
+	return nil
+}
+
+// close закрывает подключение, если оно было открыто.
+func (a *app) close() {
+	if a.db != nil {
+		a.db.Close()
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/handlers"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+// record — представление подписки для вывода, импорта и экспорта: поля
+// запроса API и то, чего в нём нет, — id, приостановки и время создания.
+type record struct {
+	ID string `json:"id,omitempty"`
+	handlers.SubscriptionRequest
+	Pauses    []pauseRecord `json:"pauses,omitempty"`
+	CreatedAt *time.Time    `json:"created_at,omitempty"`
+}
+
+// pauseRecord — приостановка в записи CLI.
+type pauseRecord struct {
+	From  string  `json:"from"`
+	Until *string `json:"until,omitempty"`
+}
+
+// newRecord переводит модель хранилища в запись CLI.
+func newRecord(sub *storage.Subscription) record {
+	rec := record{
+		ID: sub.ID.String(),
+		SubscriptionRequest: handlers.SubscriptionRequest{
+			ServiceName:   sub.ServiceName,
+			Category:      sub.Category,
+			Price:         sub.Price,
+			UserID:        sub.UserID.String(),
+			StartDate:     formatMonth(sub.StartDate),
+			TrialMonths:   sub.TrialMonths,
+			BillingMonths: sub.BillingMonths,
+		},
+		CreatedAt: &sub.CreatedAt,
+	}
+	if sub.EndDate != nil {
+		end := formatMonth(*sub.EndDate)
+		rec.EndDate = &end
+	}
+	for _, p := range sub.Promotions {
+		rec.Promotions = append(rec.Promotions, handlers.PromotionBody{Months: p.Months, Price: p.Price})
+	}
+	for _, c := range sub.PriceChanges {
+		rec.PriceChanges = append(rec.PriceChanges, handlers.PriceChangeBody{From: formatMonth(c.From), Price: c.Price})
+	}
+	for _, m := range sub.Members {
+		rec.Members = append(rec.Members, handlers.MemberBody{UserID: m.UserID.String(), Share: m.Share})
+	}
+	for _, p := range sub.Pauses {
+		pause := pauseRecord{From: formatMonth(p.From)}
+		if p.Until != nil {
+			until := formatMonth(*p.Until)
+			pause.Until = &until
+		}
+		rec.Pauses = append(rec.Pauses, pause)
+	}
+	return rec
+}
+
+// toStorage проверяет запись теми же правилами, что и API, и переводит её в
+// модель хранилища и список приостановок.
+func (rec record) toStorage() (*storage.Subscription, []storage.Pause, error) {
+	sub, err := rec.SubscriptionRequest.ToStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rec.ID != "" {
+		id, err := uuid.Parse(rec.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid id %q", rec.ID)
+		}
+		sub.ID = id
+	}
+	pauses := make([]storage.Pause, 0, len(rec.Pauses))
+	for i, p := range rec.Pauses {
+		pause, err := handlers.ParsePause(p.From, p.Until)
+		if err != nil {
+			return nil, nil, fmt.Errorf("pauses[%d]: %w", i, err)
+		}
+		pauses = append(pauses, pause)
+	}
+	return sub, pauses, nil
+}
+
+// printRecords выводит подписки таблицей или JSON.
+func printRecords(w io.Writer, output string, subs []storage.Subscription) error {
+	records := make([]record, 0, len(subs))
+	for i := range subs {
+		records = append(records, newRecord(&subs[i]))
+	}
+
+	switch output {
+	case "json":
+		return writeJSON(w, records)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSERVICE\tPRICE\tUSER\tSTART\tEND\tCREATED")
+		for _, rec := range records {
+			end := "-"
+			if rec.EndDate != nil {
+				end = *rec.EndDate
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+				rec.ID, rec.ServiceName, rec.Price, rec.UserID, rec.StartDate, end,
+				rec.CreatedAt.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output %q (expected table or json)", output)
+	}
+}
+
+// printValue выводит одиночный результат таблицей «ключ-значение» или JSON.
+func printValue(w io.Writer, output string, fields []string, values map[string]any) error {
+	switch output {
+	case "json":
+		return writeJSON(w, values)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, field := range fields {
+			fmt.Fprintf(tw, "%s\t%v\n", strings.ToUpper(field), values[field])
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output %q (expected table or json)", output)
+	}
+}
+
+// writeJSON печатает значение с отступами.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// parseMonth разбирает строку MM-YYYY в первый день месяца (UTC).
+func parseMonth(value string) (time.Time, error) {
+	parsed, err := time.Parse("01-2006", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month-year format %q", value)
+	}
+	return parsed, nil
+}
+
+// formatMonth форматирует дату как MM-YYYY.
+func formatMonth(t time.Time) string {
+	return t.Format("01-2006")
+}
@@ -0,0 +1,78 @@
+// Package aggregates периодически пересчитывает материализованные помесячные
+// агрегаты подписок, из которых summary и аналитика отвечают при
+// consistency=eventual.
+package aggregates
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Store пересчитывает агрегаты за окно месяцев; его реализует storage.Store.
+type Store interface {
+	RefreshAggregates(ctx context.Context, from, to time.Time) error
+}
+
+// Refresher пересчитывает агрегаты каждые interval за окно от monthsBack
+// месяцев до текущего до monthsAhead месяцев после него.
+type Refresher struct {
+	store       Store
+	logger      *logrus.Logger
+	interval    time.Duration
+	monthsBack  int
+	monthsAhead int
+	now         func() time.Time
+}
+
+// NewRefresher создаёт фоновый пересчёт агрегатов.
+func NewRefresher(store Store, logger *logrus.Logger, interval time.Duration, monthsBack, monthsAhead int) *Refresher {
+	return &Refresher{
+		store:       store,
+		logger:      logger,
+		interval:    interval,
+		monthsBack:  monthsBack,
+		monthsAhead: monthsAhead,
+		now:         time.Now,
+	}
+}
+
+// Run пересчитывает агрегаты сразу и затем каждые interval, пока не отменён ctx.
+func (r *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			r.logger.WithError(err).Error("aggregate refresh failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce пересчитывает агрегаты за окно вокруг текущего месяца.
+func (r *Refresher) RunOnce(ctx context.Context) error {
+	from, to := Window(r.now(), r.monthsBack, r.monthsAhead)
+	start := time.Now()
+	if err := r.store.RefreshAggregates(ctx, from, to); err != nil {
+		return err
+	}
+	r.logger.WithFields(logrus.Fields{
+		"from":        from.Format("01-2006"),
+		"to":          to.Format("01-2006"),
+		"duration_ms": time.Since(start).Milliseconds(),
+	}).Debug("aggregates refreshed")
+	return nil
+}
+
+// Window возвращает первый и последний месяц окна агрегатов вокруг now.
+func Window(now time.Time, monthsBack, monthsAhead int) (time.Time, time.Time) {
+	now = now.UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return current.AddDate(0, -monthsBack, 0), current.AddDate(0, monthsAhead, 0)
+}
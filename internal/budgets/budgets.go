@@ -0,0 +1,89 @@
+// Package budgets хранит месячные бюджеты на подписки и сравнивает с ними
+// фактические и прогнозные расходы, посчитанные по правилам Store.Summary.
+package budgets
+
+import (
+	"context"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+// DefaultThresholds — пороги оповещений в процентах, если они не заданы.
+var DefaultThresholds = []int{80, 100}
+
+// Budget — месячный лимит расходов. Пустые UserID и Category расширяют
+// охват: бюджет без обоих полей — общий по всем подпискам.
+type Budget struct {
+	ID       uuid.UUID  `json:"id"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	Category *string    `json:"category,omitempty"`
+	// Amount — лимит на месяц в рублях.
+	Amount int `json:"amount"`
+	// Thresholds — пороги оповещений в процентах от Amount по возрастанию.
+	Thresholds []int     `json:"thresholds"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Status — расходы за месяц в сравнении с бюджетом.
+type Status struct {
+	Budget *Budget
+	Month  time.Time
+	// Spend — расходы за месяц; для будущих месяцев это прогноз.
+	Spend     int
+	Remaining int
+	// Percent — доля израсходованного бюджета в процентах.
+	Percent int
+	// Projected сообщает, что месяц ещё не наступил.
+	Projected bool
+	// Reached — пороги, которые достигнуты или превышены.
+	Reached []int
+}
+
+// Summarizer считает расходы по фильтру; его реализуют storage.Store и MemoryStore.
+type Summarizer interface {
+	Summary(ctx context.Context, filter storage.SummaryFilter) (int, error)
+}
+
+// Filter возвращает фильтр Summary для месяца month в охвате бюджета.
+func (b *Budget) Filter(month time.Time) storage.SummaryFilter {
+	start := monthOf(month)
+	return storage.SummaryFilter{
+		PeriodStart: start,
+		PeriodEnd:   start.AddDate(0, 1, -1),
+		UserID:      b.UserID,
+		Category:    b.Category,
+	}
+}
+
+// Evaluate считает расходы в охвате бюджета за месяц month; месяц позже
+// текущего (по now) считается прогнозом по известным на сейчас подпискам.
+func Evaluate(ctx context.Context, summarizer Summarizer, b *Budget, month, now time.Time) (*Status, error) {
+	month = monthOf(month)
+	spend, err := summarizer.Summary(ctx, b.Filter(month))
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Budget:    b,
+		Month:     month,
+		Spend:     spend,
+		Remaining: b.Amount - spend,
+		Percent:   spend * 100 / b.Amount,
+		Projected: month.After(monthOf(now)),
+	}
+	for _, threshold := range b.Thresholds {
+		if spend*100 >= threshold*b.Amount {
+			status.Reached = append(status.Reached, threshold)
+		}
+	}
+	return status, nil
+}
+
+// monthOf возвращает первый день месяца t в UTC.
+func monthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
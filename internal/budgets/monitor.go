@@ -0,0 +1,139 @@
+package budgets
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/notify"
+	"github.com/sirupsen/logrus"
+)
+
+// KindThreshold — вид уведомления о достижении порога бюджета.
+const KindThreshold = "budget_threshold"
+
+// Monitor периодически сравнивает расходы текущего месяца и прогноз на
+// следующий с бюджетами и оповещает о каждом достигнутом пороге один раз.
+type Monitor struct {
+	store      *Store
+	summarizer Summarizer
+	notifier   notify.Notifier
+	logger     *logrus.Logger
+	interval   time.Duration
+	now        func() time.Time
+}
+
+// NewMonitor создаёт монитор бюджетов.
+func NewMonitor(store *Store, summarizer Summarizer, notifier notify.Notifier, logger *logrus.Logger, interval time.Duration) *Monitor {
+	return &Monitor{
+		store:      store,
+		summarizer: summarizer,
+		notifier:   notifier,
+		logger:     logger,
+		interval:   interval,
+		now:        time.Now,
+	}
+}
+
+// Run выполняет проверки каждые interval, пока не отменён ctx.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			m.logger.WithError(err).Error("budget check failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce проверяет все бюджеты за текущий и следующий месяц.
+func (m *Monitor) RunOnce(ctx context.Context) error {
+	list, err := m.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("load budgets: %w", err)
+	}
+	now := m.now().UTC()
+	current := monthOf(now)
+	for i := range list {
+		for _, month := range []time.Time{current, current.AddDate(0, 1, 0)} {
+			status, err := Evaluate(ctx, m.summarizer, &list[i], month, now)
+			if err != nil {
+				return fmt.Errorf("evaluate budget %s: %w", list[i].ID, err)
+			}
+			for _, threshold := range status.Reached {
+				m.alert(ctx, status, threshold)
+			}
+		}
+	}
+	return nil
+}
+
+// alert резервирует запись в журнале и отправляет оповещение; при ошибке
+// отправки резерв снимается, чтобы следующий запуск повторил попытку.
+func (m *Monitor) alert(ctx context.Context, status *Status, threshold int) {
+	b := status.Budget
+	log := m.logger.WithFields(logrus.Fields{
+		"budget_id": b.ID,
+		"month":     status.Month.Format("01-2006"),
+		"threshold": threshold,
+	})
+
+	reserved, err := m.store.ReserveAlert(ctx, b.ID, status.Month, threshold)
+	if err != nil {
+		log.WithError(err).Error("failed to reserve budget alert")
+		return
+	}
+	if !reserved {
+		return
+	}
+
+	if err := m.notifier.Notify(ctx, message(status, threshold)); err != nil {
+		log.WithError(err).Warn("failed to send budget alert")
+		if err := m.store.ReleaseAlert(context.WithoutCancel(ctx), b.ID, status.Month, threshold); err != nil {
+			log.WithError(err).Error("failed to release budget alert reservation")
+		}
+		return
+	}
+	log.Debug("budget alert sent")
+}
+
+// message формирует текст оповещения о пороге.
+func message(status *Status, threshold int) notify.Message {
+	b := status.Budget
+	data := map[string]any{
+		"budget_id": b.ID.String(),
+		"month":     status.Month.Format("01-2006"),
+		"amount":    b.Amount,
+		"spend":     status.Spend,
+		"percent":   status.Percent,
+		"threshold": threshold,
+		"projected": status.Projected,
+	}
+	scope := "всех подписок"
+	if b.UserID != nil {
+		data["user_id"] = b.UserID.String()
+		scope = fmt.Sprintf("пользователя %s", b.UserID)
+	}
+	if b.Category != nil {
+		data["category"] = *b.Category
+		scope += fmt.Sprintf(" в категории %s", *b.Category)
+	}
+
+	spend, subject := "Расходы", "израсходован"
+	if status.Projected {
+		spend, subject = "Прогноз расходов", "по прогнозу будет израсходован"
+	}
+	return notify.Message{
+		Kind:    KindThreshold,
+		Subject: fmt.Sprintf("Бюджет %s %s на %d%%", scope, subject, status.Percent),
+		Body: fmt.Sprintf("%s %s за %s — %d ₽ из %d ₽ (%d%%), порог %d%% достигнут.",
+			spend, scope, status.Month.Format("01.2006"), status.Spend, b.Amount, status.Percent, threshold),
+		Data: data,
+	}
+}
@@ -0,0 +1,168 @@
+package budgets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Store хранит бюджеты и журнал отправленных оповещений в Postgres или SQLite.
+// Ошибки драйверов переводятся в ошибки storage (storage.ErrNotFound,
+// storage.ErrConstraint и другие), как у хранилища подписок.
+type Store struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+// NewStore создаёт Store на основе переданного sql.DB и его диалекта.
+func NewStore(db *sql.DB, dialect database.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// Create сохраняет бюджет и заполняет id и created_at.
+func (s *Store) Create(ctx context.Context, b *Budget) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	b.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`INSERT INTO budgets (id, user_id, category, amount, thresholds, created_at) VALUES ($1, $2, $3, $4, $5, $6)`),
+		b.ID, b.UserID, b.Category, b.Amount, joinThresholds(b.Thresholds), b.CreatedAt)
+	return storage.Translate(err)
+}
+
+// Get загружает бюджет по id.
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Budget, error) {
+	row := s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT id, user_id, category, amount, thresholds, created_at FROM budgets WHERE id = $1`), id)
+	b, err := scanBudget(row)
+	if err != nil {
+		return nil, storage.Translate(err)
+	}
+	return b, nil
+}
+
+// List возвращает все бюджеты, новые первыми.
+func (s *Store) List(ctx context.Context) ([]Budget, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, category, amount, thresholds, created_at FROM budgets ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, storage.Translate(err)
+	}
+	defer rows.Close()
+
+	result := make([]Budget, 0)
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, storage.Translate(err)
+		}
+		result = append(result, *b)
+	}
+	return result, storage.Translate(rows.Err())
+}
+
+// Update заменяет охват, сумму и пороги бюджета.
+func (s *Store) Update(ctx context.Context, b *Budget) error {
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		`UPDATE budgets SET user_id = $1, category = $2, amount = $3, thresholds = $4 WHERE id = $5 RETURNING created_at`),
+		b.UserID, b.Category, b.Amount, joinThresholds(b.Thresholds), b.ID,
+	).Scan(&b.CreatedAt)
+	return storage.Translate(err)
+}
+
+// Delete удаляет бюджет вместе с журналом его оповещений.
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(`DELETE FROM budgets WHERE id = $1`), id)
+	if err != nil {
+		return storage.Translate(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return storage.Translate(err)
+	}
+	if rows == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// ReserveAlert отмечает оповещение о пороге threshold за месяц month как
+// отправляемое; false — его уже отправили.
+func (s *Store) ReserveAlert(ctx context.Context, budgetID uuid.UUID, month time.Time, threshold int) (bool, error) {
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`INSERT INTO budget_alerts (budget_id, month, threshold, sent_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`),
+		budgetID, month, threshold, time.Now().UTC())
+	if err != nil {
+		return false, storage.Translate(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, storage.Translate(err)
+	}
+	return rows == 1, nil
+}
+
+// ReleaseAlert снимает резерв, если оповещение не удалось доставить.
+func (s *Store) ReleaseAlert(ctx context.Context, budgetID uuid.UUID, month time.Time, threshold int) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM budget_alerts WHERE budget_id = $1 AND month = $2 AND threshold = $3`),
+		budgetID, month, threshold)
+	return storage.Translate(err)
+}
+
+func scanBudget(scanner interface {
+	Scan(dest ...any) error
+}) (*Budget, error) {
+	var b Budget
+	var userID uuid.NullUUID
+	var category sql.NullString
+	var thresholds string
+	if err := scanner.Scan(&b.ID, &userID, &category, &b.Amount, &thresholds, &b.CreatedAt); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		b.UserID = &userID.UUID
+	}
+	if category.Valid {
+		b.Category = &category.String
+	}
+	parsed, err := splitThresholds(thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("budget %s: %w", b.ID, err)
+	}
+	b.Thresholds = parsed
+	return &b, nil
+}
+
+// joinThresholds и splitThresholds переводят пороги в строку "80,100" и обратно.
+func joinThresholds(thresholds []int) string {
+	parts := make([]string, 0, len(thresholds))
+	for _, t := range thresholds {
+		parts = append(parts, strconv.Itoa(t))
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitThresholds(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, ",")
+	result := make([]int, 0, len(parts))
+	for _, p := range parts {
+		t, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q", p)
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package budgets
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/migrate"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/BaikalMine/em-subscription-service/migrations"
+	"github.com/google/uuid"
+)
+
+// newSQLiteStore открывает чистую SQLite-базу во временном каталоге и
+// применяет миграции.
+func newSQLiteStore(t *testing.T) *Store {
+	t.Helper()
+	ctx := context.Background()
+	cfg := &config.Config{DBDriver: config.DriverSQLite, SQLitePath: filepath.Join(t.TempDir(), "test.db")}
+	db, dialect, err := database.Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	source, err := migrations.For(cfg.DBDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(db, dialect, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewStore(db, dialect)
+}
+
+// TestStoreErrors проверяет, что ошибки базы приходят как ошибки storage.
+func TestStoreErrors(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	missing := uuid.New()
+
+	if _, err := store.Get(ctx, missing); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get: got %v, want ErrNotFound", err)
+	}
+	if err := store.Update(ctx, &Budget{ID: missing, Amount: 100, Thresholds: DefaultThresholds}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Update: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, missing); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Delete: got %v, want ErrNotFound", err)
+	}
+
+	b := &Budget{Amount: 100, Thresholds: DefaultThresholds}
+	if err := store.Create(ctx, b); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := store.Create(ctx, &Budget{ID: b.ID, Amount: 100, Thresholds: DefaultThresholds}); !errors.Is(err, storage.ErrDuplicate) {
+		t.Errorf("Create with existing id: got %v, want ErrDuplicate", err)
+	}
+	if err := store.Create(ctx, &Budget{Amount: 0, Thresholds: DefaultThresholds}); !errors.Is(err, storage.ErrConstraint) {
+		t.Errorf("Create with zero amount: got %v, want ErrConstraint", err)
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis — Backend поверх Redis или совместимого сервера; кэш и поколения
+// инвалидации общие для всех экземпляров сервиса. Ключи получают префикс prefix.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis создаёт Backend поверх клиента Redis.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// Get возвращает значение по ключу; отсутствие ключа — не ошибка.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set сохраняет значение; ttl = 0 оставляет ключ без срока жизни.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// generationAll — поколение, которое меняет любая запись.
+const generationAll = "gen:all"
+
+// generationTTLFactor — во сколько раз поколение живёт дольше записей кэша.
+const generationTTLFactor = 2
+
+// SummaryCache кэширует Summary поверх репозитория и сбрасывает затронутые
+// записи при изменении подписок через него. Вместо удаления ключей меняются
+// поколения: пользователя, сервиса и общее. Ключ ответа включает поколение,
+// от которого зависит фильтр, поэтому после записи старые ответы больше не
+// читаются и вытесняются по TTL или LRU. Поколения живут дольше записей, а
+// пропавшее (истёкшее или вытесненное) поколение заменяется новым, так что
+// ответы, посчитанные при нём, тоже больше не читаются.
+type SummaryCache struct {
+	storage.SubscriptionRepository
+	backend Backend
+	ttl     time.Duration
+	logger  *logrus.Logger
+
+	hits, misses, errors atomic.Int64
+}
+
+// Stats — счётчики обращений к кэшу.
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Errors int64 `json:"errors"`
+}
+
+// NewSummaryCache оборачивает repo кэшем Summary с временем жизни ttl.
+func NewSummaryCache(repo storage.SubscriptionRepository, backend Backend, ttl time.Duration, logger *logrus.Logger) *SummaryCache {
+	return &SummaryCache{SubscriptionRepository: repo, backend: backend, ttl: ttl, logger: logger}
+}
+
+// Stats возвращает число попаданий, промахов и ошибок хранилища кэша.
+func (c *SummaryCache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Errors: c.errors.Load()}
+}
+
+// Summary отдаёт сумму из кэша или считает её и сохраняет. Ошибки хранилища
+// кэша не мешают ответу: сумма считается по репозиторию.
+func (c *SummaryCache) Summary(ctx context.Context, filter storage.SummaryFilter) (int, error) {
+	key, err := c.key(ctx, filter)
+	if err == nil {
+		var value []byte
+		var ok bool
+		value, ok, err = c.backend.Get(ctx, key)
+		if err == nil && ok {
+			if total, parseErr := strconv.Atoi(string(value)); parseErr == nil {
+				c.hits.Add(1)
+				return total, nil
+			}
+		}
+	}
+	if err != nil {
+		c.fail(err, "summary cache read failed")
+	}
+	c.misses.Add(1)
+
+	total, err := c.SubscriptionRepository.Summary(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if key != "" {
+		if err := c.backend.Set(ctx, key, []byte(strconv.Itoa(total)), c.ttl); err != nil {
+			c.fail(err, "summary cache write failed")
+		}
+	}
+	return total, nil
+}
+
+// Create создаёт подписку и сбрасывает зависящие от неё суммы.
+func (c *SummaryCache) Create(ctx context.Context, sub *storage.Subscription) error {
+	if err := c.SubscriptionRepository.Create(ctx, sub); err != nil {
+		return err
+	}
+	c.invalidate(ctx, sub)
+	return nil
+}
+
+// Update обновляет подписку и сбрасывает суммы для прежних и новых
+// пользователей и сервиса.
+func (c *SummaryCache) Update(ctx context.Context, sub *storage.Subscription) error {
+	previous, _ := c.SubscriptionRepository.Get(ctx, sub.ID)
+	if err := c.SubscriptionRepository.Update(ctx, sub); err != nil {
+		return err
+	}
+	c.invalidate(ctx, previous, sub)
+	return nil
+}
+
+// Delete удаляет подписку и сбрасывает зависящие от неё суммы.
+func (c *SummaryCache) Delete(ctx context.Context, id uuid.UUID) error {
+	previous, _ := c.SubscriptionRepository.Get(ctx, id)
+	if err := c.SubscriptionRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	c.invalidate(ctx, previous)
+	return nil
+}
+
+// Cancel отменяет подписку и сбрасывает зависящие от неё суммы.
+func (c *SummaryCache) Cancel(ctx context.Context, id uuid.UUID, month time.Time) (*storage.Subscription, error) {
+	sub, err := c.SubscriptionRepository.Cancel(ctx, id, month)
+	if err == nil {
+		c.invalidate(ctx, sub)
+	}
+	return sub, err
+}
+
+// Pause приостанавливает подписку и сбрасывает зависящие от неё суммы.
+func (c *SummaryCache) Pause(ctx context.Context, id uuid.UUID, pause storage.Pause) (*storage.Subscription, error) {
+	sub, err := c.SubscriptionRepository.Pause(ctx, id, pause)
+	if err == nil {
+		c.invalidate(ctx, sub)
+	}
+	return sub, err
+}
+
+// Resume возобновляет подписку и сбрасывает зависящие от неё суммы.
+func (c *SummaryCache) Resume(ctx context.Context, id uuid.UUID, month time.Time) (*storage.Subscription, error) {
+	sub, err := c.SubscriptionRepository.Resume(ctx, id, month)
+	if err == nil {
+		c.invalidate(ctx, sub)
+	}
+	return sub, err
+}
+
+// key строит ключ по нормализованному фильтру и текущему поколению, от
+// которого зависит результат: пользователя, если фильтр по нему, сервиса,
+// если название задано без шаблонов, иначе общему.
+func (c *SummaryCache) key(ctx context.Context, filter storage.SummaryFilter) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s", filter.PeriodStart.UTC().Format("2006-01"), filter.PeriodEnd.UTC().Format("2006-01"))
+	generation := generationAll
+	if filter.UserID != nil {
+		fmt.Fprintf(&b, "|user=%s", filter.UserID)
+		generation = userGeneration(*filter.UserID)
+	}
+	if filter.ServiceName != nil {
+		service := strings.ToLower(strings.TrimSpace(*filter.ServiceName))
+		fmt.Fprintf(&b, "|service=%s", service)
+		if filter.UserID == nil && !strings.ContainsAny(service, `%_\`) {
+			generation = serviceGeneration(service)
+		}
+	}
+	if filter.Category != nil {
+		fmt.Fprintf(&b, "|category=%s", strings.ToLower(strings.TrimSpace(*filter.Category)))
+	}
+	if filter.AllowStale {
+		b.WriteString("|stale")
+	}
+
+	value, ok, err := c.backend.Get(ctx, generation)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		value = newGeneration()
+		if err := c.backend.Set(ctx, generation, value, c.ttl*generationTTLFactor); err != nil {
+			return "", err
+		}
+	}
+	fmt.Fprintf(&b, "|%s=%s", generation, value)
+	sum := sha256.Sum256([]byte(b.String()))
+	return "summary:" + hex.EncodeToString(sum[:]), nil
+}
+
+// invalidate меняет поколения пользователей и сервисов подписок subs и
+// общее поколение. nil в subs пропускаются.
+func (c *SummaryCache) invalidate(ctx context.Context, subs ...*storage.Subscription) {
+	keys := map[string]bool{generationAll: true}
+	for _, sub := range subs {
+		if sub == nil {
+			continue
+		}
+		keys[userGeneration(sub.UserID)] = true
+		for _, m := range sub.Members {
+			keys[userGeneration(m.UserID)] = true
+		}
+		keys[serviceGeneration(strings.ToLower(sub.ServiceName))] = true
+	}
+
+	value := newGeneration()
+	for key := range keys {
+		if err := c.backend.Set(ctx, key, value, c.ttl*generationTTLFactor); err != nil {
+			c.fail(err, "summary cache invalidation failed")
+		}
+	}
+}
+
+func (c *SummaryCache) fail(err error, message string) {
+	c.errors.Add(1)
+	c.logger.WithError(err).Warn(message)
+}
+
+// newGeneration возвращает значение нового поколения.
+func newGeneration() []byte {
+	return []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+}
+
+func userGeneration(user uuid.UUID) string {
+	return "gen:user:" + user.String()
+}
+
+func serviceGeneration(service string) string {
+	return "gen:service:" + service
+}
@@ -2,21 +2,150 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Поддерживаемые значения DB_DRIVER.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// Режимы проверки пересекающихся подписок (OVERLAP_MODE).
+const (
+	OverlapReject = "reject"
+	OverlapWarn   = "warn"
+	OverlapAllow  = "allow"
+)
+
+// Хранилища кэша summary (SUMMARY_CACHE).
+const (
+	CacheOff    = "off"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+// Форматы логов (LOG_FORMAT).
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// Экспортёры трейсов (TRACING_EXPORTER).
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
 // Config содержит параметры окружения, необходимые сервису подписок.
 type Config struct {
 	ServerPort string
+	// DBDriver выбирает хранилище подписок: postgres, sqlite или memory.
+	DBDriver   string
+	SQLitePath string
 	DBHost     string
 	DBPort     string
 	DBUser     string
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
-	LogLevel   string
+	// Пул соединений с базой. DBConnMaxLifetime = 0 не ограничивает возраст
+	// соединения. DBStatementTimeout ограничивает каждый запрос в Postgres
+	// (statement_timeout), 0 — без ограничения; для других драйверов он
+	// должен быть 0.
+	DBMaxOpenConns     int
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  time.Duration
+	DBConnMaxIdleTime  time.Duration
+	DBStatementTimeout time.Duration
+	LogLevel           string
+	// LogFormat выбирает формат логов: text или json.
+	LogFormat string
+	// MigrateOnStart включает применение миграций при старте сервера.
+	MigrateOnStart bool
+
+	// RemindersEnabled включает фоновую рассылку напоминаний о списаниях.
+	RemindersEnabled bool
+	ReminderInterval time.Duration
+	ReminderLeadTime time.Duration
+
+	// BudgetAlertsEnabled включает фоновую проверку бюджетов и оповещения о порогах.
+	BudgetAlertsEnabled bool
+	BudgetCheckInterval time.Duration
+	// AggregatesEnabled включает фоновый пересчёт помесячных агрегатов за
+	// AggregateMonthsBack месяцев до текущего и AggregateMonthsAhead после.
+	AggregatesEnabled        bool
+	AggregateRefreshInterval time.Duration
+	AggregateMonthsBack      int
+	AggregateMonthsAhead     int
+
+	// Notifier выбирает канал уведомлений: log, webhook или smtp.
+	Notifier         string
+	NotifyWebhookURL string
+	NotifyRetries    int
+	SMTPHost         string
+	SMTPPort         string
+	SMTPUsername     string
+	SMTPPassword     string
+	SMTPFrom         string
+	SMTPTo           []string
+
+	// Параметры доставки исходящих вебхуков.
+	WebhookPollInterval time.Duration
+	WebhookTimeout      time.Duration
+	WebhookMaxAttempts  int
+	WebhookBackoff      time.Duration
+
+	// Параметры ретранслятора событий из таблицы outbox.
+	OutboxPollInterval time.Duration
+	OutboxRetention    time.Duration
+
+	// SummaryCache выбирает кэш ответов summary: off, memory или redis.
+	SummaryCache     string
+	SummaryCacheTTL  time.Duration
+	SummaryCacheSize int
+	RedisAddr        string
+	RedisPassword    string
+	RedisDB          int
+
+	// BusinessMetricsInterval — как часто пересчитываются бизнес-метрики
+	// (число действующих подписок и расходы за месяц).
+	BusinessMetricsInterval time.Duration
+
+	// TracingExporter выбирает экспортёр трейсов: none, stdout или otlp
+	// (OTLP/HTTP на TracingEndpoint). TracingSampleRatio — доля записываемых
+	// трейсов, начатых сервисом.
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingSampleRatio float64
+
+	// Таймауты HTTP-сервера. RequestTimeout ограничивает обработку запроса:
+	// по его истечении контекст запроса отменяется и клиент получает 504.
+	ServerReadTimeout       time.Duration
+	ServerReadHeaderTimeout time.Duration
+	ServerWriteTimeout      time.Duration
+	ServerIdleTimeout       time.Duration
+	RequestTimeout          time.Duration
+	// ShutdownDrainDelay — сколько после сигнала остановки /readyz отвечает
+	// 503, прежде чем сервер перестанет принимать соединения.
+	ShutdownDrainDelay time.Duration
+	// ShutdownTimeout — сколько сервер ждёт завершения текущих запросов при
+	// остановке.
+	ShutdownTimeout time.Duration
+
+	// IdempotencyTTL — сколько хранятся ответы на запросы с Idempotency-Key.
+	IdempotencyTTL time.Duration
+	// OverlapMode задаёт реакцию на пересечение подписок одного пользователя
+	// на один сервис: reject, warn или allow.
+	OverlapMode string
 }
 
 // Load читает переменные окружения (с .env при наличии) и формирует конфигурацию.
@@ -25,6 +154,8 @@ func Load() (*Config, error) {
 
 	cfg := &Config{
 		ServerPort: getEnv("SERVER_PORT", "8080"),
+		DBDriver:   getEnv("DB_DRIVER", DriverPostgres),
+		SQLitePath: getEnv("SQLITE_PATH", "subscriptions.db"),
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "postgres"),
@@ -32,15 +163,263 @@ func Load() (*Config, error) {
 		DBName:     getEnv("DB_NAME", "subscriptions"),
 		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		LogLevel:   getEnv("LOG_LEVEL", "info"),
+		LogFormat:  getEnv("LOG_FORMAT", LogText),
+
+		OverlapMode: getEnv("OVERLAP_MODE", OverlapWarn),
+
+		TracingExporter: getEnv("TRACING_EXPORTER", TracingNone),
+		TracingEndpoint: getEnv("TRACING_OTLP_ENDPOINT", "http://localhost:4318/v1/traces"),
+
+		SummaryCache:  getEnv("SUMMARY_CACHE", CacheMemory),
+		RedisAddr:     os.Getenv("REDIS_ADDR"),
+		RedisPassword: os.Getenv("REDIS_PASSWORD"),
+
+		Notifier:         getEnv("NOTIFIER", "log"),
+		NotifyWebhookURL: os.Getenv("NOTIFY_WEBHOOK_URL"),
+		SMTPHost:         os.Getenv("SMTP_HOST"),
+		SMTPPort:         getEnv("SMTP_PORT", "587"),
+		SMTPUsername:     os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:     os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:         os.Getenv("SMTP_FROM"),
+		SMTPTo:           getEnvList("SMTP_TO"),
+	}
+
+	var err error
+	if cfg.MigrateOnStart, err = getEnvBool("MIGRATE_ON_START", true); err != nil {
+		return nil, err
+	}
+	if cfg.RemindersEnabled, err = getEnvBool("REMINDERS_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.ReminderInterval, err = getEnvDuration("REMINDER_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.ReminderLeadTime, err = getEnvDuration("REMINDER_LEAD_TIME", 72*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.BudgetAlertsEnabled, err = getEnvBool("BUDGET_ALERTS_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.BudgetCheckInterval, err = getEnvDuration("BUDGET_CHECK_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.AggregatesEnabled, err = getEnvBool("AGGREGATES_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.AggregateRefreshInterval, err = getEnvDuration("AGGREGATE_REFRESH_INTERVAL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.AggregateMonthsBack, err = getEnvInt("AGGREGATE_MONTHS_BACK", 36); err != nil {
+		return nil, err
+	}
+	if cfg.AggregateMonthsAhead, err = getEnvInt("AGGREGATE_MONTHS_AHEAD", 24); err != nil {
+		return nil, err
+	}
+	if cfg.NotifyRetries, err = getEnvInt("NOTIFY_RETRIES", 3); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookPollInterval, err = getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookTimeout, err = getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookMaxAttempts, err = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookBackoff, err = getEnvDuration("WEBHOOK_BACKOFF", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.OutboxPollInterval, err = getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.OutboxRetention, err = getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyTTL, err = getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.SummaryCacheTTL, err = getEnvDuration("SUMMARY_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.SummaryCacheSize, err = getEnvInt("SUMMARY_CACHE_SIZE", 1024); err != nil {
+		return nil, err
+	}
+	if cfg.RedisDB, err = getEnvInt("REDIS_DB", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBMaxOpenConns, err = getEnvInt("DB_MAX_OPEN_CONNS", 25); err != nil {
+		return nil, err
+	}
+	if cfg.DBMaxIdleConns, err = getEnvInt("DB_MAX_IDLE_CONNS", 10); err != nil {
+		return nil, err
+	}
+	if cfg.DBConnMaxLifetime, err = getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.DBConnMaxIdleTime, err = getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	// В SQLite statement_timeout нет, поэтому по умолчанию таймаут там не задан.
+	statementTimeout := time.Duration(0)
+	if cfg.DBDriver == DriverPostgres {
+		statementTimeout = 30 * time.Second
+	}
+	if cfg.DBStatementTimeout, err = getEnvDuration("DB_STATEMENT_TIMEOUT", statementTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.ServerReadTimeout, err = getEnvDuration("SERVER_READ_TIMEOUT", 15*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ServerReadHeaderTimeout, err = getEnvDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ServerWriteTimeout, err = getEnvDuration("SERVER_WRITE_TIMEOUT", 70*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ServerIdleTimeout, err = getEnvDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.RequestTimeout, err = getEnvDuration("REQUEST_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownDrainDelay, err = getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.TracingSampleRatio, err = getEnvFloat("TRACING_SAMPLE_RATIO", 1); err != nil {
+		return nil, err
+	}
+	if cfg.BusinessMetricsInterval, err = getEnvDuration("BUSINESS_METRICS_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	switch cfg.DBDriver {
+	case DriverPostgres, DriverSQLite, DriverMemory:
+	default:
+		return nil, fmt.Errorf("DB_DRIVER must be one of %s, %s, %s; got %q", DriverPostgres, DriverSQLite, DriverMemory, cfg.DBDriver)
+	}
+
+	switch cfg.OverlapMode {
+	case OverlapReject, OverlapWarn, OverlapAllow:
+	default:
+		return nil, fmt.Errorf("OVERLAP_MODE must be one of %s, %s, %s; got %q", OverlapReject, OverlapWarn, OverlapAllow, cfg.OverlapMode)
+	}
+
+	switch cfg.LogFormat {
+	case LogText, LogJSON:
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT must be one of %s, %s; got %q", LogText, LogJSON, cfg.LogFormat)
+	}
+
+	switch cfg.Notifier {
+	case "log":
+	case "webhook":
+		if cfg.NotifyWebhookURL == "" {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL is required when NOTIFIER=webhook")
+		}
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.SMTPFrom == "" || len(cfg.SMTPTo) == 0 {
+			return nil, fmt.Errorf("SMTP_HOST, SMTP_FROM and SMTP_TO are required when NOTIFIER=smtp")
+		}
+	default:
+		return nil, fmt.Errorf("NOTIFIER must be one of log, webhook, smtp; got %q", cfg.Notifier)
+	}
+	if cfg.ReminderInterval <= 0 {
+		return nil, fmt.Errorf("REMINDER_INTERVAL must be positive")
+	}
+	if cfg.BudgetCheckInterval <= 0 {
+		return nil, fmt.Errorf("BUDGET_CHECK_INTERVAL must be positive")
+	}
+	if cfg.AggregateRefreshInterval <= 0 {
+		return nil, fmt.Errorf("AGGREGATE_REFRESH_INTERVAL must be positive")
+	}
+	if cfg.AggregateMonthsBack < 0 || cfg.AggregateMonthsAhead < 0 {
+		return nil, fmt.Errorf("AGGREGATE_MONTHS_BACK and AGGREGATE_MONTHS_AHEAD must not be negative")
+	}
+	if cfg.WebhookPollInterval <= 0 || cfg.WebhookTimeout <= 0 || cfg.WebhookBackoff <= 0 {
+		return nil, fmt.Errorf("WEBHOOK_POLL_INTERVAL, WEBHOOK_TIMEOUT and WEBHOOK_BACKOFF must be positive")
+	}
+	if cfg.OutboxPollInterval <= 0 {
+		return nil, fmt.Errorf("OUTBOX_POLL_INTERVAL must be positive")
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_TTL must be positive")
+	}
+	switch cfg.SummaryCache {
+	case CacheOff:
+	case CacheMemory:
+		if cfg.SummaryCacheSize < 1 {
+			return nil, fmt.Errorf("SUMMARY_CACHE_SIZE must be at least 1")
+		}
+	case CacheRedis:
+		if cfg.RedisAddr == "" {
+			return nil, fmt.Errorf("REDIS_ADDR is required when SUMMARY_CACHE=redis")
+		}
+	default:
+		return nil, fmt.Errorf("SUMMARY_CACHE must be one of %s, %s, %s; got %q", CacheOff, CacheMemory, CacheRedis, cfg.SummaryCache)
+	}
+	if cfg.SummaryCacheTTL <= 0 {
+		return nil, fmt.Errorf("SUMMARY_CACHE_TTL must be positive")
+	}
+	if cfg.BusinessMetricsInterval <= 0 {
+		return nil, fmt.Errorf("BUSINESS_METRICS_INTERVAL must be positive")
+	}
+	switch cfg.TracingExporter {
+	case TracingNone, TracingStdout:
+	case TracingOTLP:
+		if u, err := url.Parse(cfg.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("TRACING_OTLP_ENDPOINT must be an http or https URL; got %q", cfg.TracingEndpoint)
+		}
+	default:
+		return nil, fmt.Errorf("TRACING_EXPORTER must be one of %s, %s, %s; got %q", TracingNone, TracingStdout, TracingOTLP, cfg.TracingExporter)
+	}
+	if cfg.TracingSampleRatio < 0 || cfg.TracingSampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+	if cfg.DBMaxOpenConns < 1 {
+		return nil, fmt.Errorf("DB_MAX_OPEN_CONNS must be at least 1")
+	}
+	if cfg.DBMaxIdleConns < 0 || cfg.DBMaxIdleConns > cfg.DBMaxOpenConns {
+		return nil, fmt.Errorf("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS (%d)", cfg.DBMaxOpenConns)
+	}
+	if cfg.DBConnMaxLifetime < 0 || cfg.DBConnMaxIdleTime < 0 || cfg.DBStatementTimeout < 0 {
+		return nil, fmt.Errorf("DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME and DB_STATEMENT_TIMEOUT must not be negative")
+	}
+	if cfg.DBStatementTimeout > 0 && cfg.DBStatementTimeout < time.Millisecond {
+		return nil, fmt.Errorf("DB_STATEMENT_TIMEOUT must be at least 1ms")
+	}
+	if cfg.DBStatementTimeout != 0 && cfg.DBDriver == DriverSQLite {
+		return nil, fmt.Errorf("DB_STATEMENT_TIMEOUT is supported only with DB_DRIVER=%s; use REQUEST_TIMEOUT to limit queries", DriverPostgres)
+	}
+	if cfg.ServerReadTimeout <= 0 || cfg.ServerReadHeaderTimeout <= 0 || cfg.ServerWriteTimeout <= 0 ||
+		cfg.ServerIdleTimeout <= 0 || cfg.RequestTimeout <= 0 || cfg.ShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("SERVER_READ_TIMEOUT, SERVER_READ_HEADER_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT, REQUEST_TIMEOUT and SHUTDOWN_TIMEOUT must be positive")
+	}
+	if cfg.ServerReadHeaderTimeout > cfg.ServerReadTimeout {
+		return nil, fmt.Errorf("SERVER_READ_HEADER_TIMEOUT must not exceed SERVER_READ_TIMEOUT")
+	}
+	// Иначе сервер закроет соединение раньше, чем клиент получит 504.
+	if cfg.ServerWriteTimeout <= cfg.RequestTimeout {
+		return nil, fmt.Errorf("SERVER_WRITE_TIMEOUT must be greater than REQUEST_TIMEOUT")
+	}
+	if cfg.ShutdownDrainDelay < 0 {
+		return nil, fmt.Errorf("SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+	if cfg.WebhookMaxAttempts < 1 {
+		return nil, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1")
 	}
 
 	return cfg, nil
 }
 
 // DSN собирает строку подключения к Postgres на основе конфигурации.
+// statement_timeout передаётся серверу параметром сессии.
 func (c *Config) DSN() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode)
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s statement_timeout=%d",
+		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.DBSSLMode, c.DBStatementTimeout.Milliseconds())
 }
 
 func getEnv(key, fallback string) string {
@@ -50,3 +429,66 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	// Разбираем булеву переменную окружения, пустое значение даёт дефолт.
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	// Разбираем целочисленную переменную окружения, пустое значение даёт дефолт.
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	// Разбираем дробную переменную окружения, пустое значение даёт дефолт.
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	// Разбираем длительность в формате time.ParseDuration (например, 30s, 1h).
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration: %w", key, err)
+	}
+	return parsed, nil
+}
+
+func getEnvList(key string) []string {
+	// Делим значение по запятым и отбрасываем пустые элементы.
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
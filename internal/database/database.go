@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/XSAM/otelsql"
+	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect описывает СУБД, под которую формируются запросы.
+type Dialect string
+
+// Поддерживаемые диалекты совпадают со значениями DB_DRIVER.
+const (
+	Postgres Dialect = config.DriverPostgres
+	SQLite   Dialect = config.DriverSQLite
+)
+
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// Rebind переводит плейсхолдеры $N в синтаксис диалекта (?N для SQLite).
+func (d Dialect) Rebind(query string) string {
+	if d != SQLite {
+		return query
+	}
+	return placeholder.ReplaceAllString(query, "?$1")
+}
+
+// Open открывает пул соединений к базе с размером и сроками жизни соединений
+// из конфигурации и проверяет её доступность. Драйвер обёрнут otelsql: каждый
+// запрос и транзакция — спан OpenTelemetry, дочерний к спану из контекста,
+// поэтому трассируются и запросы фоновых задач.
+func Open(ctx context.Context, cfg *config.Config) (*sql.DB, Dialect, error) {
+	var (
+		db      *sql.DB
+		dialect Dialect
+		err     error
+	)
+	switch cfg.DBDriver {
+	case config.DriverPostgres:
+		dialect = Postgres
+		db, err = otelsql.Open("postgres", cfg.DSN(), traceOptions(semconv.DBSystemNamePostgreSQL)...)
+	case config.DriverSQLite:
+		dialect = SQLite
+		db, err = otelsql.Open("sqlite", sqliteDSN(cfg.SQLitePath), traceOptions(semconv.DBSystemNameSQLite)...)
+	default:
+		return nil, "", fmt.Errorf("driver %q has no database", cfg.DBDriver)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("open database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, "", fmt.Errorf("ping database: %w", err)
+	}
+
+	return db, dialect, nil
+}
+
+// traceOptions настраивает спаны запросов: СУБД system, db.operation.name по
+// первому ключевому слову запроса и без спанов на каждую строку результата и
+// сброс сессии.
+func traceOptions(system attribute.KeyValue) []otelsql.Option {
+	return []otelsql.Option{
+		otelsql.WithAttributes(system),
+		otelsql.WithAttributesGetter(func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) []attribute.KeyValue {
+			if operation := statementOperation(query); operation != "" {
+				return []attribute.KeyValue{semconv.DBOperationName(operation)}
+			}
+			return nil
+		}),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitRows: true, OmitConnResetSession: true}),
+	}
+}
+
+// statementOperation возвращает SQL-операцию запроса (SELECT, INSERT, UPDATE,
+// DELETE) по первому слову или пустую строку для остальных, в том числе для
+// запросов с WITH, где операция стоит после CTE.
+func statementOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	switch word := strings.ToUpper(fields[0]); word {
+	case "SELECT", "INSERT", "UPDATE", "DELETE":
+		return word
+	default:
+		return ""
+	}
+}
+
+// sqliteDSN включает внешние ключи, ожидание блокировок и WAL, берёт
+// блокировку записи в начале транзакции и хранит время в формате,
+// понятном функциям дат SQLite.
+func sqliteDSN(path string) string {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Set("_txlock", "immediate")
+	q.Set("_time_format", "sqlite")
+	return "file:" + path + "?" + q.Encode()
+}
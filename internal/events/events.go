@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий жизненного цикла подписки.
+const (
+	SubscriptionCreated = "subscription.created"
+	SubscriptionUpdated = "subscription.updated"
+	SubscriptionDeleted = "subscription.deleted"
+
+	SubscriptionCancelled = "subscription.cancelled"
+	SubscriptionPaused    = "subscription.paused"
+	SubscriptionResumed   = "subscription.resumed"
+)
+
+// Types перечисляет все известные типы событий.
+var Types = []string{
+	SubscriptionCreated, SubscriptionUpdated, SubscriptionDeleted,
+	SubscriptionCancelled, SubscriptionPaused, SubscriptionResumed,
+}
+
+// Event — доменное событие, которое получают внешние потребители.
+type Event struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Publisher принимает события для дальнейшей доставки.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// New создаёт событие заданного типа с сериализованными данными.
+func New(eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
+
+// IsKnown сообщает, является ли eventType одним из известных типов.
+func IsKnown(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+)
+
+// maxActivityMonths ограничивает период помесячной статистики.
+const maxActivityMonths = 120
+
+// activity возвращает по месяцам периода число действующих, новых и
+// закончившихся подписок.
+func (h *Handler) activity(w http.ResponseWriter, r *http.Request) {
+	filter, err := buildSummaryFilter(r)
+	if err == nil && monthsInPeriod(filter) > maxActivityMonths {
+		var errs validationErrors
+		errs.add("end", codeOutOfRange, fmt.Sprintf("period must be at most %d months", maxActivityMonths))
+		err = errs
+	}
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	list, err := h.store.Activity(r.Context(), filter)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to calculate activity")
+		return
+	}
+
+	resp := make([]activityResponse, 0, len(list))
+	for _, a := range list {
+		resp = append(resp, activityResponse{Month: formatMonthYear(a.Month), Active: a.Active, New: a.New, Ended: a.Ended})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// servicePrices возвращает среднюю и медианную цену по сервисам.
+func (h *Handler) servicePrices(w http.ResponseWriter, r *http.Request) {
+	filter, err := buildSummaryFilter(r)
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	list, err := h.store.ServicePrices(r.Context(), filter)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to calculate prices")
+		return
+	}
+
+	resp := make([]servicePriceResponse, 0, len(list))
+	for _, p := range list {
+		resp = append(resp, servicePriceResponse{
+			ServiceName:   p.ServiceName,
+			Subscriptions: p.Subscriptions,
+			AveragePrice:  round2(p.AveragePrice),
+			MedianPrice:   round2(p.MedianPrice),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// lifetime возвращает среднюю продолжительность подписок в месяцах.
+func (h *Handler) lifetime(w http.ResponseWriter, r *http.Request) {
+	filter, err := buildSummaryFilter(r)
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	l, err := h.store.Lifetime(r.Context(), filter)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to calculate lifetime")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lifetimeResponse{
+		Subscriptions:      l.Subscriptions,
+		AverageMonths:      round2(l.AverageMonths),
+		Ended:              l.Ended,
+		AverageEndedMonths: round2(l.AverageEndedMonths),
+	})
+}
+
+// monthsInPeriod возвращает число календарных месяцев в периоде фильтра.
+func monthsInPeriod(filter storage.SummaryFilter) int {
+	start, end := filter.PeriodStart, filter.PeriodEnd
+	return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+}
+
+// round2 округляет значение до копеек.
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+type activityResponse struct {
+	Month  string `json:"month"`
+	Active int    `json:"active"`
+	New    int    `json:"new"`
+	Ended  int    `json:"ended"`
+}
+
+type servicePriceResponse struct {
+	ServiceName   string  `json:"service_name"`
+	Subscriptions int     `json:"subscriptions"`
+	AveragePrice  float64 `json:"average_price"`
+	MedianPrice   float64 `json:"median_price"`
+}
+
+type lifetimeResponse struct {
+	Subscriptions int     `json:"subscriptions"`
+	AverageMonths float64 `json:"average_months"`
+	// Ended и AverageEndedMonths учитывают только закончившиеся подписки.
+	Ended              int     `json:"ended"`
+	AverageEndedMonths float64 `json:"average_ended_months"`
+}
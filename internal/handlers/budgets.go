@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/budgets"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// maxThresholds ограничивает число порогов оповещения у бюджета.
+const maxThresholds = 10
+
+// BudgetHandler обслуживает бюджеты и сравнение расходов с ними.
+type BudgetHandler struct {
+	store      *budgets.Store
+	summarizer budgets.Summarizer
+}
+
+// NewBudgetHandler создаёт обработчик бюджетов; расходы считает summarizer.
+func NewBudgetHandler(store *budgets.Store, summarizer budgets.Summarizer) *BudgetHandler {
+	return &BudgetHandler{store: store, summarizer: summarizer}
+}
+
+// RegisterRoutes регистрирует маршруты бюджетов на роутере.
+func (h *BudgetHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/budgets", func(r chi.Router) {
+		r.Get("/", h.listBudgets)
+		r.Post("/", h.createBudget)
+		r.Get("/{id}", h.getBudget)
+		r.Put("/{id}", h.updateBudget)
+		r.Delete("/{id}", h.deleteBudget)
+		r.Get("/{id}/status", h.budgetStatus)
+	})
+}
+
+func (h *BudgetHandler) createBudget(w http.ResponseWriter, r *http.Request) {
+	var req budgetRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		logHandled(r, err)
+		writeDecodeProblem(w, r, err)
+		return
+	}
+	b, err := req.toBudget()
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	if err := h.store.Create(r.Context(), b); err != nil {
+		h.writeBudgetError(w, r, err, "unable to persist budget")
+		return
+	}
+	writeJSON(w, http.StatusCreated, convertBudget(b))
+}
+
+func (h *BudgetHandler) listBudgets(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.List(r.Context())
+	if err != nil {
+		h.writeBudgetError(w, r, err, "unable to fetch budgets")
+		return
+	}
+
+	resp := make([]budgetResponse, 0, len(list))
+	for i := range list {
+		resp = append(resp, convertBudget(&list[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *BudgetHandler) getBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	b, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		h.writeBudgetError(w, r, err, "failed to load budget")
+		return
+	}
+	writeJSON(w, http.StatusOK, convertBudget(b))
+}
+
+func (h *BudgetHandler) updateBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+	var req budgetRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		logHandled(r, err)
+		writeDecodeProblem(w, r, err)
+		return
+	}
+	b, err := req.toBudget()
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	b.ID = id
+	if err := h.store.Update(r.Context(), b); err != nil {
+		h.writeBudgetError(w, r, err, "unable to update budget")
+		return
+	}
+	writeJSON(w, http.StatusOK, convertBudget(b))
+}
+
+func (h *BudgetHandler) deleteBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), id); err != nil {
+		h.writeBudgetError(w, r, err, "unable to remove budget")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// budgetStatus сравнивает расходы за месяц month (по умолчанию текущий) с
+// бюджетом; для будущих месяцев расходы — прогноз.
+func (h *BudgetHandler) budgetStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+	now := time.Now().UTC()
+	month := startOfMonth(now)
+	if value := strings.TrimSpace(r.URL.Query().Get("month")); value != "" {
+		var errs validationErrors
+		parsed, ok := parseMonthField(&errs, "month", value)
+		if !ok {
+			logHandled(r, errs)
+			writeValidationProblem(w, r, errs)
+			return
+		}
+		month = startOfMonth(parsed)
+	}
+
+	b, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		h.writeBudgetError(w, r, err, "failed to load budget")
+		return
+	}
+	status, err := budgets.Evaluate(r.Context(), h.summarizer, b, month, now)
+	if err != nil {
+		h.writeBudgetError(w, r, err, "unable to calculate spend")
+		return
+	}
+	writeJSON(w, http.StatusOK, convertBudgetStatus(status))
+}
+
+// budgetConstraintFields связывает имена ограничений таблицы budgets с полями
+// запроса.
+var budgetConstraintFields = map[string]fieldError{
+	"budgets_amount_check": {Field: "amount", Code: codeOutOfRange, Message: "amount must be positive"},
+}
+
+// writeBudgetError отвечает статусом, соответствующим виду ошибки хранилища,
+// как writeStoreError; остальные ошибки превращаются в 500 с сообщением fallback.
+func (h *BudgetHandler) writeBudgetError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var p problemDetails
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		p = newProblem(http.StatusNotFound, "budget not found")
+	case errors.Is(err, storage.ErrDuplicate):
+		p = newProblem(http.StatusConflict, "budget already exists")
+		p.Type = problemDuplicate
+	case errors.Is(err, storage.ErrConflict):
+		p = newProblem(http.StatusConflict, "budget was modified concurrently, retry the request")
+	case errors.Is(err, storage.ErrConstraint):
+		p = newProblem(http.StatusUnprocessableEntity, "budget violates a data constraint")
+		var storeErr *storage.Error
+		if errors.As(err, &storeErr) && storeErr.Constraint != "" {
+			p.Detail = fmt.Sprintf("budget violates constraint %s", storeErr.Constraint)
+			if field, ok := budgetConstraintFields[storeErr.Constraint]; ok {
+				p.Detail = field.Message
+				p.Errors = []fieldError{field}
+			}
+		}
+	default:
+		p = newProblem(http.StatusInternalServerError, fallback)
+	}
+	logHandled(r, err)
+	writeProblem(w, r, p)
+}
+
+// toBudget проверяет запрос; без thresholds используются budgets.DefaultThresholds.
+func (req *budgetRequest) toBudget() (*budgets.Budget, error) {
+	var errs validationErrors
+	b := &budgets.Budget{Amount: req.Amount}
+	if req.UserID != nil && strings.TrimSpace(*req.UserID) != "" {
+		uid, err := uuid.Parse(strings.TrimSpace(*req.UserID))
+		if err != nil {
+			errs.add("user_id", codeInvalidFormat, "invalid user_id")
+		} else {
+			b.UserID = &uid
+		}
+	}
+	b.Category = normalizeCategory(&errs, "category", req.Category)
+	if req.Amount <= 0 {
+		errs.add("amount", codeOutOfRange, "amount must be positive")
+	}
+
+	thresholds := req.Thresholds
+	if thresholds == nil {
+		thresholds = budgets.DefaultThresholds
+	}
+	if len(thresholds) > maxThresholds {
+		errs.add("thresholds", codeTooLong, fmt.Sprintf("at most %d thresholds are allowed", maxThresholds))
+	}
+	seen := make(map[int]bool, len(thresholds))
+	for i, t := range thresholds {
+		switch {
+		case t < 1 || t > 1000:
+			errs.add(fmt.Sprintf("thresholds[%d]", i), codeOutOfRange, "threshold must be between 1 and 1000 percent")
+		case seen[t]:
+			errs.add(fmt.Sprintf("thresholds[%d]", i), codeUnknownValue, "threshold is listed more than once")
+		}
+		seen[t] = true
+	}
+	b.Thresholds = append([]int(nil), thresholds...)
+	sort.Ints(b.Thresholds)
+	return b, errs.err()
+}
+
+// convertBudget собирает ответ API из бюджета.
+func convertBudget(b *budgets.Budget) budgetResponse {
+	resp := budgetResponse{
+		ID:         b.ID.String(),
+		Category:   b.Category,
+		Amount:     b.Amount,
+		Thresholds: b.Thresholds,
+		CreatedAt:  b.CreatedAt,
+	}
+	if b.UserID != nil {
+		uid := b.UserID.String()
+		resp.UserID = &uid
+	}
+	return resp
+}
+
+// convertBudgetStatus собирает ответ API из сравнения расходов с бюджетом.
+func convertBudgetStatus(s *budgets.Status) budgetStatusResponse {
+	reached := s.Reached
+	if reached == nil {
+		reached = []int{}
+	}
+	return budgetStatusResponse{
+		BudgetID:  s.Budget.ID.String(),
+		Month:     formatMonthYear(s.Month),
+		Amount:    s.Budget.Amount,
+		Spend:     s.Spend,
+		Remaining: s.Remaining,
+		Percent:   s.Percent,
+		Projected: s.Projected,
+		Reached:   reached,
+	}
+}
+
+type budgetRequest struct {
+	UserID     *string `json:"user_id"`
+	Category   *string `json:"category"`
+	Amount     int     `json:"amount"`
+	Thresholds []int   `json:"thresholds"`
+}
+
+type budgetResponse struct {
+	ID         string    `json:"id"`
+	UserID     *string   `json:"user_id,omitempty"`
+	Category   *string   `json:"category,omitempty"`
+	Amount     int       `json:"amount"`
+	Thresholds []int     `json:"thresholds"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type budgetStatusResponse struct {
+	BudgetID  string `json:"budget_id"`
+	Month     string `json:"month"`
+	Amount    int    `json:"amount"`
+	Spend     int    `json:"spend"`
+	Remaining int    `json:"remaining"`
+	Percent   int    `json:"percent"`
+	Projected bool   `json:"projected"`
+	Reached   []int  `json:"reached_thresholds"`
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// TestWriteBudgetError проверяет, что ошибки хранилища бюджетов отображаются
+// на статусы так же, как ошибки подписок.
+func TestWriteBudgetError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantType   string
+		wantField  string
+	}{
+		{name: "not found", err: storage.ErrNotFound, wantStatus: http.StatusNotFound, wantType: problemNotFound},
+		{name: "duplicate", err: &storage.Error{Kind: storage.ErrDuplicate}, wantStatus: http.StatusConflict, wantType: problemDuplicate},
+		{name: "conflict", err: &storage.Error{Kind: storage.ErrConflict}, wantStatus: http.StatusConflict, wantType: problemConflict},
+		{name: "constraint", err: &storage.Error{Kind: storage.ErrConstraint, Constraint: "budgets_amount_check"},
+			wantStatus: http.StatusUnprocessableEntity, wantType: problemConstraint, wantField: "amount"},
+		{name: "other", err: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantType: problemInternal},
+	}
+
+	h := &BudgetHandler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/budgets/1", nil)
+			rec := httptest.NewRecorder()
+			middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.writeBudgetError(w, r, tt.err, "failed")
+			})).ServeHTTP(rec, req)
+
+			p := decodeProblem(t, rec, tt.wantStatus)
+			if p.Type != tt.wantType {
+				t.Errorf("type = %q, want %q", p.Type, tt.wantType)
+			}
+			if tt.wantField != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.wantField) {
+				t.Errorf("errors = %+v, want one error for %q", p.Errors, tt.wantField)
+			}
+		})
+	}
+}
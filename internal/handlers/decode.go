@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxBodyBytes ограничивает размер тела запроса; запросы API намного меньше.
+const maxBodyBytes = 64 << 10
+
+// errUnsupportedMediaType возвращается, если тело прислано не как JSON.
+var errUnsupportedMediaType = errors.New("content type must be application/json")
+
+// decodeJSON строго читает тело запроса в dst: требует Content-Type
+// application/json, ограничивает размер, запрещает неизвестные поля и
+// больше одного JSON-значения. Ошибки полей возвращаются как validationErrors.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return decodeError(err)
+		}
+		return errors.New("body must contain a single JSON object")
+	}
+	return nil
+}
+
+// decodeError переводит ошибки encoding/json в ошибки полей, где это возможно.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeErr):
+		return sizeErr
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		var errs validationErrors
+		errs.add(typeErr.Field, codeInvalidType, fmt.Sprintf("%s must be %s", typeErr.Field, jsonTypeName(typeErr.Type)))
+		return errs
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		var errs validationErrors
+		errs.add(field, codeUnknownField, fmt.Sprintf("unknown field %q", field))
+		return errs
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("malformed JSON body")
+	case errors.Is(err, io.EOF):
+		return errors.New("request body must not be empty")
+	default:
+		return errors.New("invalid body")
+	}
+}
+
+// jsonTypeName называет ожидаемый тип так, как его видит клиент JSON API.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "an array"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	default:
+		return "an object"
+	}
+}
+
+// decodeStatus возвращает HTTP-статус для ошибки decodeJSON.
+func decodeStatus(err error) int {
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.As(err, &sizeErr):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// writeDecodeProblem отвечает на ошибку decodeJSON подходящим статусом.
+func writeDecodeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	switch status := decodeStatus(err); status {
+	case http.StatusUnsupportedMediaType:
+		writeProblem(w, r, newProblem(status, err.Error()))
+	case http.StatusRequestEntityTooLarge:
+		writeProblem(w, r, newProblem(status, fmt.Sprintf("request body must not exceed %d bytes", maxBodyBytes)))
+	default:
+		writeValidationProblem(w, r, err)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultForecastMonths — горизонт прогноза, если months не задан.
+	defaultForecastMonths = 12
+	// maxForecastMonths ограничивает горизонт прогноза.
+	maxForecastMonths = 60
+)
+
+// forecast прогнозирует расходы на months месяцев вперёд, начиная со start
+// (по умолчанию следующий месяц).
+func (h *Handler) forecast(w http.ResponseWriter, r *http.Request) {
+	filter, err := buildForecastFilter(r, time.Now().UTC())
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	series, err := h.store.Forecast(r.Context(), filter)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to calculate forecast")
+		return
+	}
+
+	resp := forecastResponse{
+		From:   formatMonthYear(filter.PeriodStart),
+		To:     formatMonthYear(filter.PeriodEnd),
+		Series: make([]forecastMonth, 0, len(series)),
+	}
+	for _, m := range series {
+		resp.Total += m.Total
+		resp.Series = append(resp.Series, forecastMonth{
+			Month:         formatMonthYear(m.Month),
+			Total:         m.Total,
+			Subscriptions: m.Subscriptions,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// buildForecastFilter формирует фильтр прогноза из query параметров; now
+// задаёт текущий месяц для start по умолчанию.
+func buildForecastFilter(r *http.Request, now time.Time) (storage.SummaryFilter, error) {
+	var filter storage.SummaryFilter
+	var errs validationErrors
+	query := r.URL.Query()
+
+	start := startOfMonth(now).AddDate(0, 1, 0)
+	if value := strings.TrimSpace(query.Get("start")); value != "" {
+		if parsed, ok := parseMonthField(&errs, "start", value); ok {
+			start = startOfMonth(parsed)
+		}
+	}
+	months := defaultForecastMonths
+	if value := strings.TrimSpace(query.Get("months")); value != "" {
+		val, err := strconv.Atoi(value)
+		switch {
+		case err != nil:
+			errs.add("months", codeInvalidFormat, "months must be an integer")
+		case val < 1 || val > maxForecastMonths:
+			errs.add("months", codeOutOfRange, fmt.Sprintf("months must be between 1 and %d", maxForecastMonths))
+		default:
+			months = val
+		}
+	}
+	filter.PeriodStart = start
+	filter.PeriodEnd = endOfMonth(start.AddDate(0, months-1, 0))
+
+	if user := strings.TrimSpace(query.Get("user_id")); user != "" {
+		uid, err := uuid.Parse(user)
+		if err != nil {
+			errs.add("user_id", codeInvalidFormat, "invalid user_id")
+		} else {
+			filter.UserID = &uid
+		}
+	}
+	if service := strings.TrimSpace(query.Get("service_name")); service != "" {
+		filter.ServiceName = &service
+	}
+	if category := strings.TrimSpace(query.Get("category")); category != "" {
+		filter.Category = &category
+	}
+	return filter, errs.err()
+}
+
+type forecastResponse struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Total int    `json:"total"`
+	// Series — прогноз по месяцам от From до To включительно.
+	Series []forecastMonth `json:"series"`
+}
+
+type forecastMonth struct {
+	Month string `json:"month"`
+	Total int    `json:"total"`
+	// Subscriptions — число подписок со списанием в этом месяце.
+	Subscriptions int `json:"subscriptions"`
+}
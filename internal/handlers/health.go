@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/BaikalMine/em-subscription-service/internal/health"
+	"github.com/BaikalMine/em-subscription-service/internal/logging"
+	"github.com/go-chi/chi/v5"
+)
+
+// HealthHandler отвечает на проверки живости и готовности.
+type HealthHandler struct {
+	probe *health.Probe
+}
+
+// NewHealthHandler создаёт обработчик проверок над probe.
+func NewHealthHandler(probe *health.Probe) *HealthHandler {
+	return &HealthHandler{probe: probe}
+}
+
+// RegisterRoutes регистрирует /healthz и /readyz на роутере.
+func (h *HealthHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/healthz", h.live)
+	r.Get("/readyz", h.ready)
+}
+
+type checkBody struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type healthBody struct {
+	Status string               `json:"status"`
+	Checks map[string]checkBody `json:"checks,omitempty"`
+}
+
+// live отвечает 200, пока процесс обслуживает запросы.
+func (h *HealthHandler) live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthBody{Status: "ok"})
+}
+
+// ready отвечает 200, если все проверки прошли, иначе 503; в теле — итог
+// каждой проверки. Текст ошибок может раскрывать устройство базы, поэтому
+// он пишется только в лог.
+func (h *HealthHandler) ready(w http.ResponseWriter, r *http.Request) {
+	results, ready := h.probe.Ready(r.Context())
+	body := healthBody{Status: "ok", Checks: make(map[string]checkBody, len(results))}
+	for _, res := range results {
+		check := checkBody{Status: "ok", DurationMS: res.Duration.Milliseconds()}
+		if res.Err != nil {
+			check.Status = "fail"
+			logging.FromContext(r.Context()).WithError(res.Err).WithField("check", res.Name).Warn("readiness check failed")
+		}
+		body.Checks[res.Name] = check
+	}
+
+	status := http.StatusOK
+	if !ready {
+		body.Status, status = "fail", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, body)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/logging"
+)
+
+const (
+	// headerIdempotencyKey — заголовок, которым клиент помечает повторяемый запрос.
+	headerIdempotencyKey = "Idempotency-Key"
+	// headerIdempotentReplay отмечает ответ, взятый из сохранённых.
+	headerIdempotentReplay = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	problemKeyReused     = "/problems/idempotency-key-reused"
+	problemKeyInProgress = "/problems/idempotency-key-in-progress"
+)
+
+// idempotent сохраняет ответ на запрос с заголовком Idempotency-Key и
+// возвращает его на повторы с тем же телом. Повтор ключа с другим телом
+// отклоняется с 422, а повтор во время выполнения первого запроса — с 409.
+// Ответы 5xx не сохраняются, чтобы клиент мог повторить запрос.
+func (h *Handler) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(headerIdempotencyKey))
+		if key == "" || h.keys == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			var errs validationErrors
+			errs.add(headerIdempotencyKey, codeTooLong, "Idempotency-Key must be at most 255 characters")
+			logHandled(r, errs)
+			writeValidationProblem(w, r, errs)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			err = decodeError(err)
+			logHandled(r, err)
+			writeDecodeProblem(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := requestHash(r, body)
+		existing, err := h.keys.Reserve(r.Context(), key, hash, time.Now().Add(h.cfg.IdempotencyTTL))
+		if err != nil {
+			logHandled(r, err)
+			writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to check idempotency key"))
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != hash:
+				p := newProblem(http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+				p.Type = problemKeyReused
+				writeProblem(w, r, p)
+			case !existing.Completed():
+				p := newProblem(http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+				p.Type = problemKeyInProgress
+				writeProblem(w, r, p)
+			default:
+				w.Header().Set("Content-Type", existing.ContentType)
+				w.Header().Set(headerIdempotentReplay, "true")
+				w.WriteHeader(existing.StatusCode)
+				_, _ = w.Write(existing.Body)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// При панике в обработчике резерв снимается, иначе ключ останется
+			// занятым до истечения; сама паника передаётся дальше.
+			panicked := recover()
+			// Результат сохраняем и при отмене запроса по той же причине.
+			saveCtx := context.WithoutCancel(r.Context())
+			var err error
+			if panicked != nil || rec.status >= http.StatusInternalServerError {
+				err = h.keys.Release(saveCtx, key)
+			} else {
+				err = h.keys.Complete(saveCtx, key, rec.status, w.Header().Get("Content-Type"), rec.body.Bytes())
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).WithError(err).WithField("idempotency_key", key).Error("failed to store idempotent response")
+			}
+			if panicked != nil {
+				panic(panicked)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// requestHash связывает ключ с конкретным запросом: методом, путём и телом.
+func requestHash(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// responseRecorder пропускает ответ клиенту и запоминает статус и тело.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
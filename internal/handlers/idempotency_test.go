@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/idempotency"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+)
+
+// TestIdempotent проверяет, как middleware idempotent отвечает на повторы
+// ключа в зависимости от результата первого запроса.
+func TestIdempotent(t *testing.T) {
+	tests := []struct {
+		name string
+		// first — ответ обработчика на первый запрос; panic означает панику.
+		first string
+		// retryBody — тело повтора с тем же ключом.
+		retryBody   string
+		wantStatus  int
+		wantCalls   int
+		wantReplay  bool
+		wantProblem string
+	}{
+		{name: "completed is replayed", first: "created", retryBody: `{"n":1}`, wantStatus: http.StatusCreated, wantCalls: 1, wantReplay: true},
+		{name: "different body", first: "created", retryBody: `{"n":2}`, wantStatus: http.StatusUnprocessableEntity, wantCalls: 1, wantProblem: problemKeyReused},
+		{name: "5xx releases key", first: "failed", retryBody: `{"n":1}`, wantStatus: http.StatusCreated, wantCalls: 2},
+		{name: "panic releases key", first: "panic", retryBody: `{"n":1}`, wantStatus: http.StatusCreated, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewMemoryStore(), idempotency.NewMemoryStore(), HandlerConfig{IdempotencyTTL: time.Hour})
+			calls := 0
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					switch tt.first {
+					case "panic":
+						panic("boom")
+					case "failed":
+						writeProblem(w, r, newProblem(http.StatusInternalServerError, "unavailable"))
+						return
+					}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"call":1}`))
+			})
+			handler := h.idempotent(next)
+
+			send := func(body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+				req.Header.Set(headerIdempotencyKey, "key-1")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != (tt.first == "panic") {
+						t.Fatalf("first request panic = %v", p)
+					}
+				}()
+				send(`{"n":1}`)
+			}()
+
+			rec := send(tt.retryBody)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("retry status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+			if got := rec.Header().Get(headerIdempotentReplay) == "true"; got != tt.wantReplay {
+				t.Errorf("%s header present = %v, want %v", headerIdempotentReplay, got, tt.wantReplay)
+			}
+			if tt.wantReplay && rec.Body.String() != `{"call":1}` {
+				t.Errorf("replayed body = %s", rec.Body)
+			}
+			if tt.wantProblem != "" {
+				var p problemDetails
+				if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.Type != tt.wantProblem {
+					t.Errorf("problem = %+v (%v), want type %s", p, err, tt.wantProblem)
+				}
+			}
+		})
+	}
+}
+
+// TestIdempotentInProgress проверяет, что повтор во время выполнения первого
+// запроса получает 409.
+func TestIdempotentInProgress(t *testing.T) {
+	h := NewHandler(storage.NewMemoryStore(), idempotency.NewMemoryStore(), HandlerConfig{IdempotencyTTL: time.Hour})
+	var inner *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = h.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{}`))
+		req.Header.Set(headerIdempotencyKey, "key-1")
+		inner = httptest.NewRecorder()
+		handler.ServeHTTP(inner, req)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{}`))
+	req.Header.Set(headerIdempotencyKey, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if inner == nil || inner.Code != http.StatusConflict {
+		t.Fatalf("retry during first request: got %v, want 409", inner)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (h *Handler) cancelSubscription(w http.ResponseWriter, r *http.Request) {
+	var req cancelRequest
+	h.changeLifecycle(w, r, &req, func(ctx context.Context, id uuid.UUID) (*storage.Subscription, error) {
+		return h.store.Cancel(ctx, id, req.month)
+	})
+}
+
+func (h *Handler) pauseSubscription(w http.ResponseWriter, r *http.Request) {
+	var req pauseRequest
+	h.changeLifecycle(w, r, &req, func(ctx context.Context, id uuid.UUID) (*storage.Subscription, error) {
+		return h.store.Pause(ctx, id, req.pause)
+	})
+}
+
+func (h *Handler) resumeSubscription(w http.ResponseWriter, r *http.Request) {
+	var req resumeRequest
+	h.changeLifecycle(w, r, &req, func(ctx context.Context, id uuid.UUID) (*storage.Subscription, error) {
+		return h.store.Resume(ctx, id, req.month)
+	})
+}
+
+// lifecycleRequest — тело запроса на смену состояния подписки.
+type lifecycleRequest interface {
+	validate() error
+}
+
+// changeLifecycle разбирает id и тело запроса, вызывает change и отвечает
+// обновлённой подпиской.
+func (h *Handler) changeLifecycle(w http.ResponseWriter, r *http.Request, req lifecycleRequest,
+	change func(ctx context.Context, id uuid.UUID) (*storage.Subscription, error)) {
+	subID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+	if err := decodeJSON(w, r, req); err != nil {
+		logHandled(r, err)
+		writeDecodeProblem(w, r, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	sub, err := change(r.Context(), subID)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to change subscription state")
+		return
+	}
+	writeJSON(w, http.StatusOK, convertResponse(sub))
+}
+
+type cancelRequest struct {
+	// EffectiveMonth — первый месяц, за который подписка уже не оплачивается.
+	EffectiveMonth string `json:"effective_month"`
+
+	month time.Time
+}
+
+func (req *cancelRequest) validate() error {
+	var errs validationErrors
+	month, _ := parseMonthField(&errs, "effective_month", req.EffectiveMonth)
+	req.month = startOfMonth(month)
+	return errs.err()
+}
+
+type pauseRequest struct {
+	From  string  `json:"from"`
+	Until *string `json:"until"`
+
+	pause storage.Pause
+}
+
+func (req *pauseRequest) validate() error {
+	pause, err := ParsePause(req.From, req.Until)
+	req.pause = pause
+	return err
+}
+
+// ParsePause проверяет приостановку с месяца from по until (MM-YYYY, until
+// необязателен). Её же используют записи, которые импортирует subctl.
+func ParsePause(from string, until *string) (storage.Pause, error) {
+	var errs validationErrors
+	var pause storage.Pause
+	start, fromOK := parseMonthField(&errs, "from", from)
+	pause.From = startOfMonth(start)
+	if until != nil {
+		end, err := parseMonthYear(*until)
+		switch {
+		case err != nil:
+			errs.add("until", codeInvalidFormat, err.Error())
+		case fromOK && end.Before(start):
+			errs.add("until", codeOutOfRange, "until must not be before from")
+		default:
+			parsed := startOfMonth(end)
+			pause.Until = &parsed
+		}
+	}
+	return pause, errs.err()
+}
+
+type resumeRequest struct {
+	// Month — первый месяц, с которого подписка снова оплачивается.
+	Month string `json:"month"`
+
+	month time.Time
+}
+
+func (req *resumeRequest) validate() error {
+	var errs validationErrors
+	month, _ := parseMonthField(&errs, "month", req.Month)
+	req.month = startOfMonth(month)
+	return errs.err()
+}
+
+// convertPause собирает ответ API из приостановки.
+func convertPause(p storage.Pause) pauseResponse {
+	resp := pauseResponse{From: formatMonthYear(p.From)}
+	if p.Until != nil {
+		until := formatMonthYear(*p.Until)
+		resp.Until = &until
+	}
+	return resp
+}
+
+type pauseResponse struct {
+	From  string  `json:"from"`
+	Until *string `json:"until,omitempty"`
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+// warningOverlap — код предупреждения о пересечении с другими подписками.
+const warningOverlap = "overlap"
+
+// overlapWarnings в режиме warn ищет подписки того же пользователя на тот же
+// сервис с пересекающимся периодом и возвращает предупреждение о них. В
+// режиме reject пересечения отклоняет само хранилище в транзакции записи, см.
+// storage.Store.RejectOverlaps. false означает, что ответ уже отправлен и
+// обработку нужно прекратить.
+func (h *Handler) overlapWarnings(w http.ResponseWriter, r *http.Request, sub *storage.Subscription) ([]responseWarning, bool) {
+	if h.cfg.OverlapMode != config.OverlapWarn {
+		return nil, true
+	}
+
+	overlapping, err := h.store.Overlapping(r.Context(), sub)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to check overlapping subscriptions")
+		return nil, false
+	}
+	if len(overlapping) == 0 {
+		return nil, true
+	}
+	message, ids := describeOverlaps(sub.ServiceName, overlapping)
+	return []responseWarning{{Code: warningOverlap, Message: message, SubscriptionIDs: ids}}, true
+}
+
+// overlapProblem описывает отказ хранилища записать пересекающуюся подписку.
+func overlapProblem(err error) problemDetails {
+	var overlapErr *storage.OverlapError
+	if !errors.As(err, &overlapErr) || len(overlapErr.Subscriptions) == 0 {
+		p := newProblem(http.StatusConflict, "subscription overlaps existing subscriptions of the same user")
+		p.Type = problemOverlap
+		return p
+	}
+	message, ids := describeOverlaps(overlapErr.Subscriptions[0].ServiceName, overlapErr.Subscriptions)
+	p := newProblem(http.StatusConflict, message)
+	p.Type = problemOverlap
+	p.OverlappingIDs = ids
+	return p
+}
+
+// describeOverlaps возвращает сообщение о пересечениях и id пересекающихся подписок.
+func describeOverlaps(service string, overlapping []storage.Subscription) (string, []string) {
+	ids := make([]string, 0, len(overlapping))
+	for _, other := range overlapping {
+		ids = append(ids, other.ID.String())
+	}
+	return fmt.Sprintf("subscription to %s overlaps %d existing subscription(s) of the same user", service, len(ids)), ids
+}
+
+func (h *Handler) listOverlaps(w http.ResponseWriter, r *http.Request) {
+	filter, err := buildOverlapFilter(r)
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	overlaps, err := h.store.Overlaps(r.Context(), filter)
+	if err != nil {
+		h.writeStoreError(w, r, err, "unable to find overlapping subscriptions")
+		return
+	}
+
+	resp := make([]overlapResponse, 0, len(overlaps))
+	for i := range overlaps {
+		resp = append(resp, convertOverlap(&overlaps[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// buildOverlapFilter формирует фильтры поиска пересечений из query параметров.
+func buildOverlapFilter(r *http.Request) (storage.OverlapFilter, error) {
+	var filter storage.OverlapFilter
+	var errs validationErrors
+	query := r.URL.Query()
+	if user := strings.TrimSpace(query.Get("user_id")); user != "" {
+		uid, err := uuid.Parse(user)
+		if err != nil {
+			errs.add("user_id", codeInvalidFormat, "invalid user_id")
+		} else {
+			filter.UserID = &uid
+		}
+	}
+	if service := strings.TrimSpace(query.Get("service_name")); service != "" {
+		filter.ServiceName = &service
+	}
+	if limit := strings.TrimSpace(query.Get("limit")); limit != "" {
+		val, err := strconv.Atoi(limit)
+		if err != nil {
+			errs.add("limit", codeInvalidFormat, "limit must be an integer")
+		}
+		filter.Limit = val
+	}
+	if offset := strings.TrimSpace(query.Get("offset")); offset != "" {
+		val, err := strconv.Atoi(offset)
+		if err != nil {
+			errs.add("offset", codeInvalidFormat, "offset must be an integer")
+		}
+		filter.Offset = val
+	}
+	return filter, errs.err()
+}
+
+// convertOverlap собирает ответ API из найденного пересечения.
+func convertOverlap(o *storage.Overlap) overlapResponse {
+	resp := overlapResponse{
+		UserID:      o.First.UserID.String(),
+		ServiceName: o.First.ServiceName,
+		From:        formatMonthYear(o.From),
+		First:       convertResponse(&o.First),
+		Second:      convertResponse(&o.Second),
+	}
+	if o.To != nil {
+		to := formatMonthYear(*o.To)
+		resp.To = &to
+	}
+	return resp
+}
+
+type responseWarning struct {
+	Code            string   `json:"code"`
+	Message         string   `json:"message"`
+	SubscriptionIDs []string `json:"subscription_ids,omitempty"`
+}
+
+type overlapResponse struct {
+	UserID      string               `json:"user_id"`
+	ServiceName string               `json:"service_name"`
+	From        string               `json:"from"`
+	To          *string              `json:"to,omitempty"`
+	First       subscriptionResponse `json:"first"`
+	Second      subscriptionResponse `json:"second"`
+}
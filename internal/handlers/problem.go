@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// problemContentType — тип содержимого ответов об ошибках по RFC 7807.
+const problemContentType = "application/problem+json"
+
+// Типы проблем; клиент различает ошибки по type, а не по тексту.
+const (
+	problemValidation   = "/problems/validation-error"
+	problemBadRequest   = "/problems/bad-request"
+	problemNotFound     = "/problems/not-found"
+	problemMethod       = "/problems/method-not-allowed"
+	problemMediaType    = "/problems/unsupported-media-type"
+	problemTooLarge     = "/problems/payload-too-large"
+	problemDuplicate    = "/problems/duplicate"
+	problemConflict     = "/problems/conflict"
+	problemConstraint   = "/problems/constraint-violation"
+	problemOverlap      = "/problems/overlapping-subscription"
+	problemInvalidState = "/problems/invalid-state"
+	problemInternal     = "/problems/internal-error"
+)
+
+// Машинные коды ошибок отдельных полей.
+const (
+	codeRequired      = "required"
+	codeInvalidFormat = "invalid_format"
+	codeOutOfRange    = "out_of_range"
+	codeUnknownValue  = "unknown_value"
+	codeUnknownField  = "unknown_field"
+	codeInvalidType   = "invalid_type"
+	codeTooLong       = "too_long"
+	codeInvalidChars  = "invalid_characters"
+)
+
+// problemDetails — тело ответа application/problem+json.
+type problemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+	// OverlappingIDs перечисляет подписки, с которыми пересекается запрос.
+	OverlappingIDs []string `json:"overlapping_ids,omitempty"`
+}
+
+// fieldError описывает одно неверное поле запроса.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationErrors накапливает ошибки всех полей, чтобы вернуть их разом.
+type validationErrors []fieldError
+
+func (v validationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, f := range v {
+		messages = append(messages, f.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add добавляет ошибку поля.
+func (v *validationErrors) add(field, code, message string) {
+	*v = append(*v, fieldError{Field: field, Code: code, Message: message})
+}
+
+// err возвращает nil, если ошибок нет.
+func (v validationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// newProblem создаёт описание проблемы; type по умолчанию выводится из статуса.
+func newProblem(status int, detail string) problemDetails {
+	problemType := problemBadRequest
+	switch status {
+	case http.StatusNotFound:
+		problemType = problemNotFound
+	case http.StatusMethodNotAllowed:
+		problemType = problemMethod
+	case http.StatusUnsupportedMediaType:
+		problemType = problemMediaType
+	case http.StatusRequestEntityTooLarge:
+		problemType = problemTooLarge
+	case http.StatusConflict:
+		problemType = problemConflict
+	case http.StatusUnprocessableEntity:
+		problemType = problemConstraint
+	case http.StatusInternalServerError:
+		problemType = problemInternal
+	}
+	return problemDetails{Type: problemType, Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// writeProblem дополняет описание данными запроса и отправляет его клиенту.
+func writeProblem(w http.ResponseWriter, r *http.Request, p problemDetails) {
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// writeValidationProblem отвечает 400 со списком всех неверных полей.
+func writeValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := newProblem(http.StatusBadRequest, err.Error())
+	var fields validationErrors
+	if errors.As(err, &fields) {
+		p.Type = problemValidation
+		p.Title = "Request validation failed"
+		p.Detail = "one or more fields are invalid"
+		p.Errors = fields
+	}
+	writeProblem(w, r, p)
+}
+
+// NotFound отвечает problem+json на запросы к неизвестным маршрутам.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, newProblem(http.StatusNotFound, "route not found"))
+}
+
+// MethodNotAllowed отвечает problem+json, если маршрут не поддерживает метод.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, newProblem(http.StatusMethodNotAllowed, "method not allowed for this route"))
+}
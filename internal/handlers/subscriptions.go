@@ -1,78 +1,107 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/BaikalMine/em-subscription-service/internal/idempotency"
+	"github.com/BaikalMine/em-subscription-service/internal/logging"
 	"github.com/BaikalMine/em-subscription-service/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
 )
 
-// Handler связывает эндпоинты подписок со стором и логгером.
+// HandlerConfig задаёт параметры обработчика подписок.
+type HandlerConfig struct {
+	// IdempotencyTTL — сколько хранится ответ на запрос с Idempotency-Key.
+	IdempotencyTTL time.Duration
+	// OverlapMode — реакция на пересечение с подписками того же пользователя
+	// на тот же сервис: config.OverlapReject, OverlapWarn или OverlapAllow.
+	OverlapMode string
+}
+
+// Handler связывает эндпоинты подписок со стором.
 type Handler struct {
-	store  *storage.Store
-	logger *logrus.Logger
+	store storage.SubscriptionRepository
+	keys  idempotency.Store
+	cfg   HandlerConfig
 }
 
-// NewHandler создаёт обработчик с настроенным стором и логгером.
-func NewHandler(store *storage.Store, logger *logrus.Logger) *Handler {
-	return &Handler{store: store, logger: logger}
+// NewHandler создаёт обработчик с настроенным стором. Если keys
+// равен nil, заголовок Idempotency-Key игнорируется.
+func NewHandler(store storage.SubscriptionRepository, keys idempotency.Store, cfg HandlerConfig) *Handler {
+	return &Handler{store: store, keys: keys, cfg: cfg}
 }
 
-// RegisterRoutes регистрирует маршруты подписок на роутере.
+// RegisterRoutes регистрирует маршруты подписок и аналитики на роутере.
 func (h *Handler) RegisterRoutes(r chi.Router) {
 	r.Route("/subscriptions", func(r chi.Router) {
 		r.Get("/summary", h.summary)
+		r.Get("/forecast", h.forecast)
+		r.Get("/overlaps", h.listOverlaps)
 		r.Get("/", h.listSubscriptions)
-		r.Post("/", h.createSubscription)
+		r.With(h.idempotent).Post("/", h.createSubscription)
 		r.Get("/{id}", h.getSubscription)
 		r.Put("/{id}", h.updateSubscription)
 		r.Delete("/{id}", h.deleteSubscription)
+		r.Post("/{id}/cancel", h.cancelSubscription)
+		r.Post("/{id}/pause", h.pauseSubscription)
+		r.Post("/{id}/resume", h.resumeSubscription)
+	})
+	r.Route("/analytics", func(r chi.Router) {
+		r.Get("/activity", h.activity)
+		r.Get("/prices", h.servicePrices)
+		r.Get("/lifetime", h.lifetime)
 	})
 }
 
 func (h *Handler) createSubscription(w http.ResponseWriter, r *http.Request) {
-	var req subscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid body"})
+	var req SubscriptionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		logHandled(r, err)
+		writeDecodeProblem(w, r, err)
 		return
 	}
-	sub, err := req.toStorage()
+	sub, err := req.ToStorage()
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+
+	warnings, ok := h.overlapWarnings(w, r, sub)
+	if !ok {
 		return
 	}
 
 	if err := h.store.Create(r.Context(), sub); err != nil {
-		h.logRequest(r, http.StatusInternalServerError, err)
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "unable to persist subscription"})
+		h.writeStoreError(w, r, err, "unable to persist subscription")
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, convertResponse(sub))
+	resp := convertResponse(sub)
+	resp.Warnings = warnings
+	writeJSON(w, http.StatusCreated, resp)
 }
 
 func (h *Handler) listSubscriptions(w http.ResponseWriter, r *http.Request) {
 	filter, err := buildListFilter(r)
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
 		return
 	}
 	result, err := h.store.List(r.Context(), filter)
 	if err != nil {
-		h.logRequest(r, http.StatusInternalServerError, err)
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "unable to fetch subscriptions"})
+		h.writeStoreError(w, r, err, "unable to fetch subscriptions")
 		return
 	}
 
@@ -87,19 +116,14 @@ func (h *Handler) listSubscriptions(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) getSubscription(w http.ResponseWriter, r *http.Request) {
 	subID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid id"})
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
 		return
 	}
 
 	sub, err := h.store.Get(r.Context(), subID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeJSON(w, http.StatusNotFound, errorResponse{Error: "subscription not found"})
-			return
-		}
-		h.logRequest(r, http.StatusInternalServerError, err)
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to load subscription"})
+		h.writeStoreError(w, r, err, "failed to load subscription")
 		return
 	}
 
@@ -109,54 +133,51 @@ func (h *Handler) getSubscription(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) updateSubscription(w http.ResponseWriter, r *http.Request) {
 	subID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid id"})
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
 		return
 	}
 
-	var req subscriptionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid body"})
+	var req SubscriptionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		logHandled(r, err)
+		writeDecodeProblem(w, r, err)
 		return
 	}
 
-	sub, err := req.toStorage()
+	sub, err := req.ToStorage()
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
 		return
 	}
 
 	sub.ID = subID
+	warnings, ok := h.overlapWarnings(w, r, sub)
+	if !ok {
+		return
+	}
+
 	if err := h.store.Update(r.Context(), sub); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeJSON(w, http.StatusNotFound, errorResponse{Error: "subscription not found"})
-			return
-		}
-		h.logRequest(r, http.StatusInternalServerError, err)
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "unable to update subscription"})
+		h.writeStoreError(w, r, err, "unable to update subscription")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, convertResponse(sub))
+	resp := convertResponse(sub)
+	resp.Warnings = warnings
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (h *Handler) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 	subID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid id"})
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
 		return
 	}
 
 	if err := h.store.Delete(r.Context(), subID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeJSON(w, http.StatusNotFound, errorResponse{Error: "subscription not found"})
-			return
-		}
-		h.logRequest(r, http.StatusInternalServerError, err)
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "unable to remove subscription"})
+		h.writeStoreError(w, r, err, "unable to remove subscription")
 		return
 	}
 
@@ -164,55 +185,16 @@ func (h *Handler) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) summary(w http.ResponseWriter, r *http.Request) {
-	start := strings.TrimSpace(r.URL.Query().Get("start"))
-	end := strings.TrimSpace(r.URL.Query().Get("end"))
-	if start == "" || end == "" {
-		h.logRequest(r, http.StatusBadRequest, errors.New("missing start/end"))
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "start and end query parameters are required (format MM-YYYY)"})
-		return
-	}
-
-	periodStart, err := parseMonthYear(start)
+	filter, err := buildSummaryFilter(r)
 	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid start format"})
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
 		return
 	}
-	periodEnd, err := parseMonthYear(end)
-	if err != nil {
-		h.logRequest(r, http.StatusBadRequest, err)
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid end format"})
-		return
-	}
-	if periodEnd.Before(periodStart) {
-		h.logRequest(r, http.StatusBadRequest, errors.New("end before start"))
-		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "end must not be before start"})
-		return
-	}
-
-	filter := storage.SummaryFilter{
-		PeriodStart: startOfMonth(periodStart),
-		PeriodEnd:   endOfMonth(periodEnd),
-	}
-
-	if user := strings.TrimSpace(r.URL.Query().Get("user_id")); user != "" {
-		uid, err := uuid.Parse(user)
-		if err != nil {
-			h.logRequest(r, http.StatusBadRequest, err)
-			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
-			return
-		}
-		filter.UserID = &uid
-	}
-
-	if service := strings.TrimSpace(r.URL.Query().Get("service_name")); service != "" {
-		filter.ServiceName = &service
-	}
 
 	total, err := h.store.Summary(r.Context(), filter)
 	if err != nil {
-		h.logRequest(r, http.StatusInternalServerError, err)
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "unable to calculate total"})
+		h.writeStoreError(w, r, err, "unable to calculate total")
 		return
 	}
 
@@ -222,13 +204,15 @@ func (h *Handler) summary(w http.ResponseWriter, r *http.Request) {
 // buildListFilter формирует фильтры из query параметров для списка.
 func buildListFilter(r *http.Request) (storage.ListFilter, error) {
 	var filter storage.ListFilter
+	var errs validationErrors
 	query := r.URL.Query()
 	if user := strings.TrimSpace(query.Get("user_id")); user != "" {
 		uid, err := uuid.Parse(user)
 		if err != nil {
-			return filter, fmt.Errorf("invalid user_id")
+			errs.add("user_id", codeInvalidFormat, "invalid user_id")
+		} else {
+			filter.UserID = &uid
 		}
-		filter.UserID = &uid
 	}
 	if service := strings.TrimSpace(query.Get("service_name")); service != "" {
 		filter.ServiceName = &service
@@ -236,73 +220,317 @@ func buildListFilter(r *http.Request) (storage.ListFilter, error) {
 	if limit := strings.TrimSpace(query.Get("limit")); limit != "" {
 		val, err := strconv.Atoi(limit)
 		if err != nil {
-			return filter, fmt.Errorf("limit must be an integer")
+			errs.add("limit", codeInvalidFormat, "limit must be an integer")
 		}
 		filter.Limit = val
 	}
 	if offset := strings.TrimSpace(query.Get("offset")); offset != "" {
 		val, err := strconv.Atoi(offset)
 		if err != nil {
-			return filter, fmt.Errorf("offset must be an integer")
+			errs.add("offset", codeInvalidFormat, "offset must be an integer")
 		}
 		filter.Offset = val
 	}
-	return filter, nil
+	return filter, errs.err()
 }
 
-// toStorage переводит DTO запроса в модель хранилища.
-func (req *subscriptionRequest) toStorage() (*storage.Subscription, error) {
-	if strings.TrimSpace(req.ServiceName) == "" {
-		return nil, errors.New("service_name is required")
+// buildSummaryFilter формирует фильтр подсчёта стоимости из query параметров.
+func buildSummaryFilter(r *http.Request) (storage.SummaryFilter, error) {
+	var filter storage.SummaryFilter
+	var errs validationErrors
+	query := r.URL.Query()
+
+	periodStart, startOK := parseMonthField(&errs, "start", query.Get("start"))
+	periodEnd, endOK := parseMonthField(&errs, "end", query.Get("end"))
+	if startOK && endOK && periodEnd.Before(periodStart) {
+		errs.add("end", codeOutOfRange, "end must not be before start")
 	}
-	if req.Price < 0 {
-		return nil, errors.New("price must be non-negative")
+	filter.PeriodStart = startOfMonth(periodStart)
+	filter.PeriodEnd = endOfMonth(periodEnd)
+
+	if user := strings.TrimSpace(query.Get("user_id")); user != "" {
+		uid, err := uuid.Parse(user)
+		if err != nil {
+			errs.add("user_id", codeInvalidFormat, "invalid user_id")
+		} else {
+			filter.UserID = &uid
+		}
 	}
-	userID, err := uuid.Parse(req.UserID)
-	if err != nil {
-		return nil, errors.New("invalid user_id")
+	if service := strings.TrimSpace(query.Get("service_name")); service != "" {
+		filter.ServiceName = &service
 	}
-	if strings.TrimSpace(req.StartDate) == "" {
-		return nil, errors.New("start_date is required")
+	if category := strings.TrimSpace(query.Get("category")); category != "" {
+		filter.Category = &category
 	}
-	start, err := parseMonthYear(req.StartDate)
+	switch consistency := strings.TrimSpace(query.Get("consistency")); consistency {
+	case "", consistencyStrong:
+	case consistencyEventual:
+		filter.AllowStale = true
+	default:
+		errs.add("consistency", codeUnknownValue, fmt.Sprintf("consistency must be %s or %s", consistencyStrong, consistencyEventual))
+	}
+	return filter, errs.err()
+}
+
+// Значения параметра consistency: strong считает по подпискам, eventual
+// разрешает ответ из агрегатов, пересчитываемых в фоне.
+const (
+	consistencyStrong   = "strong"
+	consistencyEventual = "eventual"
+)
+
+// parseMonthField разбирает обязательное поле MM-YYYY и записывает ошибку в errs.
+func parseMonthField(errs *validationErrors, field, value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		errs.add(field, codeRequired, field+" is required (format MM-YYYY)")
+		return time.Time{}, false
+	}
+	parsed, err := parseMonthYear(value)
 	if err != nil {
-		return nil, err
+		errs.add(field, codeInvalidFormat, err.Error())
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// ToStorage переводит DTO запроса в модель хранилища. Проверяются все поля
+// сразу, ошибки возвращаются списком validationErrors. Те же проверки
+// проходят записи, которые импортирует subctl.
+func (req *SubscriptionRequest) ToStorage() (*storage.Subscription, error) {
+	var errs validationErrors
+	serviceName := strings.TrimSpace(req.ServiceName)
+	switch {
+	case serviceName == "":
+		errs.add("service_name", codeRequired, "service_name is required")
+	case utf8.RuneCountInString(serviceName) > maxServiceNameLength:
+		errs.add("service_name", codeTooLong, fmt.Sprintf("service_name must be at most %d characters", maxServiceNameLength))
+	case !validServiceName(serviceName):
+		errs.add("service_name", codeInvalidChars, "service_name may contain only letters, digits, spaces and . , - _ + & ' ! ( ) :")
+	}
+	category := normalizeCategory(&errs, "category", req.Category)
+	if req.Price < 0 {
+		errs.add("price", codeOutOfRange, "price must be non-negative")
+	}
+	var userID uuid.UUID
+	if strings.TrimSpace(req.UserID) == "" {
+		errs.add("user_id", codeRequired, "user_id is required")
+	} else if parsed, err := uuid.Parse(req.UserID); err != nil {
+		errs.add("user_id", codeInvalidFormat, "invalid user_id")
+	} else {
+		userID = parsed
+	}
+	start, startOK := parseMonthField(&errs, "start_date", req.StartDate)
+	if req.TrialMonths < 0 || req.TrialMonths > maxTrialMonths {
+		errs.add("trial_months", codeOutOfRange, fmt.Sprintf("trial_months must be between 0 and %d", maxTrialMonths))
+	}
+	if len(req.Promotions) > maxPromotions {
+		errs.add("promotions", codeTooLong, fmt.Sprintf("at most %d promotion steps are allowed", maxPromotions))
+	}
+	if req.BillingMonths != 0 && (req.BillingMonths < 1 || req.BillingMonths > maxBillingMonths) {
+		errs.add("billing_months", codeOutOfRange, fmt.Sprintf("billing_months must be between 1 and %d", maxBillingMonths))
+	}
+	members := req.members(&errs, userID)
+	var priceChanges []storage.PriceChange
+	if startOK {
+		priceChanges = req.priceChanges(&errs, startOfMonth(start))
+	}
+	promotions := make([]storage.Promotion, 0, len(req.Promotions))
+	for i, promo := range req.Promotions {
+		if promo.Months < 1 {
+			errs.add(fmt.Sprintf("promotions[%d].months", i), codeOutOfRange, "promotion months must be positive")
+		}
+		if promo.Price < 0 {
+			errs.add(fmt.Sprintf("promotions[%d].price", i), codeOutOfRange, "promotion price must be non-negative")
+		}
+		promotions = append(promotions, storage.Promotion{Months: promo.Months, Price: promo.Price})
 	}
 
 	var endPtr *time.Time
 	if req.EndDate != nil {
 		end, err := parseMonthYear(*req.EndDate)
-		if err != nil {
-			return nil, err
+		switch {
+		case err != nil:
+			errs.add("end_date", codeInvalidFormat, err.Error())
+		case startOK && end.Before(start):
+			errs.add("end_date", codeOutOfRange, "end_date must not be before start_date")
+		default:
+			parsed := startOfMonth(end)
+			endPtr = &parsed
 		}
-		parsed := startOfMonth(end)
-		endPtr = &parsed
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
 	}
 
 	return &storage.Subscription{
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      userID,
-		StartDate:   startOfMonth(start),
-		EndDate:     endPtr,
+		ServiceName:  serviceName,
+		Category:     category,
+		Price:        req.Price,
+		UserID:       userID,
+		StartDate:    startOfMonth(start),
+		EndDate:      endPtr,
+		TrialMonths:  req.TrialMonths,
+		Promotions:   promotions,
+		PriceChanges: priceChanges,
+		// Ноль означает ежемесячную оплату, его заменит хранилище.
+		BillingMonths: req.BillingMonths,
+		Members:       members,
 	}, nil
 }
 
+// priceChanges проверяет запланированные смены цены: месяц MM-YYYY позже
+// start, без повторов, цена неотрицательная. Результат упорядочен по месяцу.
+func (req *SubscriptionRequest) priceChanges(errs *validationErrors, start time.Time) []storage.PriceChange {
+	if len(req.PriceChanges) > maxPriceChanges {
+		errs.add("price_changes", codeTooLong, fmt.Sprintf("at most %d price changes are allowed", maxPriceChanges))
+	}
+	changes := make([]storage.PriceChange, 0, len(req.PriceChanges))
+	seen := make(map[time.Time]bool, len(req.PriceChanges))
+	for i, c := range req.PriceChanges {
+		field := fmt.Sprintf("price_changes[%d]", i)
+		from, err := parseMonthYear(strings.TrimSpace(c.From))
+		switch {
+		case err != nil:
+			errs.add(field+".from", codeInvalidFormat, err.Error())
+		case !from.After(start):
+			errs.add(field+".from", codeOutOfRange, "price change must be after start_date")
+		case seen[from]:
+			errs.add(field+".from", codeUnknownValue, "month is listed more than once")
+		}
+		seen[from] = true
+		if c.Price < 0 {
+			errs.add(field+".price", codeOutOfRange, "price must be non-negative")
+		}
+		changes = append(changes, storage.PriceChange{From: startOfMonth(from), Price: c.Price})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].From.Before(changes[j].From) })
+	return changes
+}
+
+// members проверяет участников совместной подписки: корректные id, не
+// совпадающие с владельцем owner и друг с другом, доли от 1 до 100 процентов
+// в сумме не больше 100.
+func (req *SubscriptionRequest) members(errs *validationErrors, owner uuid.UUID) []storage.Member {
+	if len(req.Members) > maxMembers {
+		errs.add("members", codeTooLong, fmt.Sprintf("at most %d members are allowed", maxMembers))
+	}
+	members := make([]storage.Member, 0, len(req.Members))
+	seen := make(map[uuid.UUID]bool, len(req.Members))
+	total := 0
+	for i, m := range req.Members {
+		field := fmt.Sprintf("members[%d]", i)
+		uid, err := uuid.Parse(strings.TrimSpace(m.UserID))
+		switch {
+		case err != nil:
+			errs.add(field+".user_id", codeInvalidFormat, "invalid user_id")
+		case uid == owner:
+			errs.add(field+".user_id", codeUnknownValue, "the owner must not be listed as a member")
+		case seen[uid]:
+			errs.add(field+".user_id", codeUnknownValue, "member is listed more than once")
+		}
+		seen[uid] = true
+		if m.Share < 1 || m.Share > 100 {
+			errs.add(field+".share", codeOutOfRange, "member share must be between 1 and 100")
+		}
+		total += m.Share
+		members = append(members, storage.Member{UserID: uid, Share: m.Share})
+	}
+	if total > 100 {
+		errs.add("members", codeOutOfRange, "member shares must not exceed 100 in total")
+	}
+	return members
+}
+
+const (
+	// maxTrialMonths ограничивает длину пробного периода.
+	maxTrialMonths = 36
+	// maxPromotions ограничивает число шагов промо-расписания.
+	maxPromotions = 12
+	// maxMembers ограничивает число участников совместной подписки.
+	maxMembers = 20
+	// maxBillingMonths — самый длинный интервал оплаты, раз в год.
+	maxBillingMonths = 12
+	// maxPriceChanges ограничивает число запланированных смен цены.
+	maxPriceChanges = 24
+)
+
+// maxServiceNameLength — максимальная длина названия сервиса в символах.
+const maxServiceNameLength = 100
+
+// maxCategoryLength — максимальная длина категории в символах.
+const maxCategoryLength = 50
+
+// normalizeCategory обрезает пробелы у необязательной категории и проверяет
+// её так же, как название сервиса; пустая категория равна её отсутствию.
+func normalizeCategory(errs *validationErrors, field string, value *string) *string {
+	if value == nil {
+		return nil
+	}
+	category := strings.TrimSpace(*value)
+	switch {
+	case category == "":
+		return nil
+	case utf8.RuneCountInString(category) > maxCategoryLength:
+		errs.add(field, codeTooLong, fmt.Sprintf("%s must be at most %d characters", field, maxCategoryLength))
+	case !validServiceName(category):
+		errs.add(field, codeInvalidChars, field+" may contain only letters, digits, spaces and . , - _ + & ' ! ( ) :")
+	}
+	return &category
+}
+
+// validServiceName проверяет, что название состоит из букв, цифр, пробелов
+// и небольшого набора знаков препинания, без управляющих символов.
+func validServiceName(name string) bool {
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == ' ':
+		case strings.ContainsRune(".,-_+&'!():", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // convertResponse собирает ответ API из модели подписки.
 func convertResponse(sub *storage.Subscription) subscriptionResponse {
 	resp := subscriptionResponse{
-		ID:          sub.ID.String(),
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		UserID:      sub.UserID.String(),
-		StartDate:   formatMonthYear(sub.StartDate),
-		CreatedAt:   sub.CreatedAt,
+		ID:            sub.ID.String(),
+		ServiceName:   sub.ServiceName,
+		Category:      sub.Category,
+		Price:         sub.Price,
+		UserID:        sub.UserID.String(),
+		StartDate:     formatMonthYear(sub.StartDate),
+		Status:        sub.Status(time.Now().UTC()),
+		TrialMonths:   sub.TrialMonths,
+		BillingMonths: sub.BillingMonths,
+		CreatedAt:     sub.CreatedAt,
 	}
 	if sub.EndDate != nil {
 		end := formatMonthYear(*sub.EndDate)
 		resp.EndDate = &end
 	}
+	if trialEnd := sub.TrialEnd(); trialEnd != nil {
+		end := formatMonthYear(*trialEnd)
+		resp.TrialEnd = &end
+	}
+	for _, promo := range sub.Promotions {
+		resp.Promotions = append(resp.Promotions, PromotionBody{Months: promo.Months, Price: promo.Price})
+	}
+	for _, c := range sub.PriceChanges {
+		resp.PriceChanges = append(resp.PriceChanges, PriceChangeBody{From: formatMonthYear(c.From), Price: c.Price})
+	}
+	if len(sub.Members) > 0 {
+		share := sub.ShareOf(sub.UserID)
+		resp.OwnerShare = &share
+	}
+	for _, m := range sub.Members {
+		resp.Members = append(resp.Members, MemberBody{UserID: m.UserID.String(), Share: m.Share})
+	}
+	for _, p := range sub.Pauses {
+		resp.Pauses = append(resp.Pauses, convertPause(p))
+	}
 	return resp
 }
 
@@ -338,38 +566,124 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-// logRequest пишет структуру запроса в лог.
-func (h *Handler) logRequest(r *http.Request, status int, err error) {
-	h.logger.WithFields(logrus.Fields{
-		"method": r.Method,
-		"path":   r.URL.Path,
-		"status": status,
-		"error":  err,
-	}).Info("handled request")
+// constraintFields связывает имена ограничений схемы с полями запроса.
+var constraintFields = map[string]fieldError{
+	"subscriptions_price_check":              {Field: "price", Code: codeOutOfRange, Message: "price must be non-negative"},
+	"subscriptions_trial_months_check":       {Field: "trial_months", Code: codeOutOfRange, Message: "trial_months must be non-negative"},
+	"subscription_promotions_months_check":   {Field: "promotions", Code: codeOutOfRange, Message: "promotion months must be positive"},
+	"subscription_promotions_price_check":    {Field: "promotions", Code: codeOutOfRange, Message: "promotion price must be non-negative"},
+	"subscription_members_share_check":       {Field: "members", Code: codeOutOfRange, Message: "member share must be between 1 and 100"},
+	"subscriptions_billing_months_check":     {Field: "billing_months", Code: codeOutOfRange, Message: "billing_months must be between 1 and 12"},
+	"subscription_price_changes_price_check": {Field: "price_changes", Code: codeOutOfRange, Message: "price change must be non-negative"},
 }
 
-type subscriptionRequest struct {
+// writeStoreError отвечает статусом, соответствующим виду ошибки хранилища;
+// остальные ошибки превращаются в 500 с сообщением fallback.
+func (h *Handler) writeStoreError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var p problemDetails
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeProblem(w, r, newProblem(http.StatusNotFound, "subscription not found"))
+		return
+	case errors.Is(err, storage.ErrDuplicate):
+		p = newProblem(http.StatusConflict, "subscription already exists")
+		p.Type = problemDuplicate
+	case errors.Is(err, storage.ErrConflict):
+		p = newProblem(http.StatusConflict, "subscription was modified concurrently, retry the request")
+	case errors.Is(err, storage.ErrOverlap):
+		p = overlapProblem(err)
+	case errors.Is(err, storage.ErrInvalidState):
+		p = newProblem(http.StatusConflict, "operation is not allowed in the current subscription state")
+		p.Type = problemInvalidState
+		var storeErr *storage.Error
+		if errors.As(err, &storeErr) {
+			p.Detail = storeErr.Err.Error()
+		}
+	case errors.Is(err, storage.ErrConstraint):
+		p = newProblem(http.StatusUnprocessableEntity, "subscription violates a data constraint")
+		var storeErr *storage.Error
+		if errors.As(err, &storeErr) && storeErr.Constraint != "" {
+			p.Detail = fmt.Sprintf("subscription violates constraint %s", storeErr.Constraint)
+			if field, ok := constraintFields[storeErr.Constraint]; ok {
+				p.Detail = field.Message
+				p.Errors = []fieldError{field}
+			}
+		}
+	default:
+		p = newProblem(http.StatusInternalServerError, fallback)
+	}
+	logHandled(r, err)
+	writeProblem(w, r, p)
+}
+
+// logHandled прикрепляет ошибку, с которой обработчик ответил, к записи о
+// запросе; запись пишет logging.Middleware с уровнем по статусу ответа.
+func logHandled(r *http.Request, err error) {
+	logging.SetError(r.Context(), err)
+}
+
+// SubscriptionRequest — тело запроса на создание и изменение подписки.
+type SubscriptionRequest struct {
 	ServiceName string  `json:"service_name"`
+	Category    *string `json:"category"`
 	Price       int     `json:"price"`
 	UserID      string  `json:"user_id"`
 	StartDate   string  `json:"start_date"`
 	EndDate     *string `json:"end_date"`
+	// TrialMonths — число бесплатных месяцев с start_date.
+	TrialMonths int             `json:"trial_months"`
+	Promotions  []PromotionBody `json:"promotions"`
+	// BillingMonths — интервал оплаты в месяцах, по умолчанию 1.
+	BillingMonths int               `json:"billing_months"`
+	PriceChanges  []PriceChangeBody `json:"price_changes"`
+	// Members — участники совместной подписки с долями в процентах.
+	Members []MemberBody `json:"members"`
+}
+
+// MemberBody — участник совместной подписки в запросе и ответе.
+type MemberBody struct {
+	UserID string `json:"user_id"`
+	Share  int    `json:"share"`
+}
+
+// PriceChangeBody — запланированная смена цены в запросе и ответе.
+type PriceChangeBody struct {
+	From  string `json:"from"`
+	Price int    `json:"price"`
+}
+
+// PromotionBody — шаг промо-расписания в запросе и ответе.
+type PromotionBody struct {
+	Months int `json:"months"`
+	Price  int `json:"price"`
 }
 
 type subscriptionResponse struct {
-	ID          string    `json:"id"`
-	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	UserID      string    `json:"user_id"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string  `json:"id"`
+	ServiceName string  `json:"service_name"`
+	Category    *string `json:"category,omitempty"`
+	Price       int     `json:"price"`
+	UserID      string  `json:"user_id"`
+	StartDate   string  `json:"start_date"`
+	EndDate     *string `json:"end_date,omitempty"`
+	// Status вычисляется на текущий месяц: active, paused, scheduled или ended.
+	Status      string `json:"status"`
+	TrialMonths int    `json:"trial_months,omitempty"`
+	// TrialEnd — последний бесплатный месяц.
+	TrialEnd   *string         `json:"trial_end,omitempty"`
+	Promotions []PromotionBody `json:"promotions,omitempty"`
+	// BillingMonths — интервал оплаты в месяцах.
+	BillingMonths int               `json:"billing_months"`
+	PriceChanges  []PriceChangeBody `json:"price_changes,omitempty"`
+	Members       []MemberBody      `json:"members,omitempty"`
+	// OwnerShare — доля владельца в процентах, есть только у совместных подписок.
+	OwnerShare *int            `json:"owner_share,omitempty"`
+	Pauses     []pauseResponse `json:"pauses,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	// Warnings есть только в ответах на создание и обновление.
+	Warnings []responseWarning `json:"warnings,omitempty"`
 }
 
 type summaryResponse struct {
 	TotalPrice int `json:"total_price"`
 }
-
-type errorResponse struct {
-	Error string `json:"error"`
-}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// newTestServer собирает роутер с обработчиком поверх хранилища в памяти.
+func newTestServer() http.Handler {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	NewHandler(storage.NewMemoryStore(), nil, HandlerConfig{}).RegisterRoutes(router)
+	return router
+}
+
+// do отправляет запрос с JSON-телом и возвращает ответ.
+func do(t *testing.T, srv http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeProblem проверяет заголовки ответа об ошибке и разбирает его тело.
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder, wantStatus int) problemDetails {
+	t.Helper()
+	if rec.Code != wantStatus {
+		t.Fatalf("status = %d, want %d (body %s)", rec.Code, wantStatus, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, problemContentType)
+	}
+	var p problemDetails
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if p.Status != wantStatus || p.RequestID == "" || p.Instance == "" {
+		t.Errorf("problem = %+v, want status %d with request_id and instance", p, wantStatus)
+	}
+	return p
+}
+
+// TestCreateValidation проверяет, что все неверные поля возвращаются разом.
+func TestCreateValidation(t *testing.T) {
+	srv := newTestServer()
+	rec := do(t, srv, http.MethodPost, "/subscriptions",
+		`{"service_name":"","price":-1,"user_id":"nope","start_date":"13-2025"}`)
+	p := decodeProblem(t, rec, http.StatusBadRequest)
+	if p.Type != problemValidation {
+		t.Errorf("type = %q, want %q", p.Type, problemValidation)
+	}
+
+	got := make(map[string]string)
+	for _, f := range p.Errors {
+		got[f.Field] = f.Code
+	}
+	want := map[string]string{
+		"service_name": codeRequired,
+		"price":        codeOutOfRange,
+		"user_id":      codeInvalidFormat,
+		"start_date":   codeInvalidFormat,
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("errors[%s] = %q, want %q (all: %+v)", field, got[field], code, p.Errors)
+		}
+	}
+}
+
+// TestDecodeProblems проверяет ответы на тела, которые не удаётся разобрать.
+func TestDecodeProblems(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantType    string
+		wantField   string
+	}{
+		{name: "unknown field", body: `{"service_name":"Video","extra":1}`, wantStatus: http.StatusBadRequest, wantType: problemValidation, wantField: "extra"},
+		{name: "wrong type", body: `{"price":"100"}`, wantStatus: http.StatusBadRequest, wantType: problemValidation, wantField: "price"},
+		{name: "malformed", body: `{"service_name":`, wantStatus: http.StatusBadRequest, wantType: problemBadRequest},
+		{name: "two objects", body: `{} {}`, wantStatus: http.StatusBadRequest, wantType: problemBadRequest},
+		{name: "oversized", body: `{"service_name":"` + strings.Repeat("a", maxBodyBytes) + `"}`, wantStatus: http.StatusRequestEntityTooLarge, wantType: problemTooLarge},
+		{name: "not json", contentType: "text/plain", body: `{}`, wantStatus: http.StatusUnsupportedMediaType, wantType: problemMediaType},
+	}
+
+	srv := newTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			p := decodeProblem(t, rec, tt.wantStatus)
+			if p.Type != tt.wantType {
+				t.Errorf("type = %q, want %q", p.Type, tt.wantType)
+			}
+			if tt.wantField != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.wantField) {
+				t.Errorf("errors = %+v, want one error for %q", p.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+// TestLifecycleEndpoints проходит отмену, приостановку и возобновление и
+// проверяет вычисляемый status.
+func TestLifecycleEndpoints(t *testing.T) {
+	now := time.Now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthParam := func(offset int) string { return current.AddDate(0, offset, 0).Format("01-2006") }
+
+	srv := newTestServer()
+	rec := do(t, srv, http.MethodPost, "/subscriptions", `{"service_name":"Video","price":100,"user_id":"`+
+		uuid.NewString()+`","start_date":"`+monthParam(-2)+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", rec.Code, rec.Body)
+	}
+	var created subscriptionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != storage.StatusActive {
+		t.Errorf("status after create = %q, want %q", created.Status, storage.StatusActive)
+	}
+	base := "/subscriptions/" + created.ID
+
+	steps := []struct {
+		name, path, body string
+		wantStatus       int
+		wantSubStatus    string
+		wantProblem      string
+	}{
+		{name: "pause", path: base + "/pause", body: `{"from":"` + monthParam(0) + `"}`, wantStatus: http.StatusOK, wantSubStatus: storage.StatusPaused},
+		{name: "pause again", path: base + "/pause", body: `{"from":"` + monthParam(1) + `"}`, wantStatus: http.StatusConflict, wantProblem: problemInvalidState},
+		{name: "resume", path: base + "/resume", body: `{"month":"` + monthParam(0) + `"}`, wantStatus: http.StatusOK, wantSubStatus: storage.StatusActive},
+		{name: "resume not paused", path: base + "/resume", body: `{"month":"` + monthParam(0) + `"}`, wantStatus: http.StatusConflict, wantProblem: problemInvalidState},
+		{name: "cancel invalid month", path: base + "/cancel", body: `{"effective_month":"2025-01"}`, wantStatus: http.StatusBadRequest, wantProblem: problemValidation},
+		{name: "cancel next month", path: base + "/cancel", body: `{"effective_month":"` + monthParam(1) + `"}`, wantStatus: http.StatusOK, wantSubStatus: storage.StatusActive},
+		{name: "cancel this month", path: base + "/cancel", body: `{"effective_month":"` + monthParam(0) + `"}`, wantStatus: http.StatusOK, wantSubStatus: storage.StatusEnded},
+		{name: "cancel missing", path: "/subscriptions/" + uuid.NewString() + "/cancel", body: `{"effective_month":"` + monthParam(0) + `"}`, wantStatus: http.StatusNotFound, wantProblem: problemNotFound},
+	}
+	for _, step := range steps {
+		rec := do(t, srv, http.MethodPost, step.path, step.body)
+		if step.wantProblem != "" {
+			if p := decodeProblem(t, rec, step.wantStatus); p.Type != step.wantProblem {
+				t.Errorf("%s: type = %q, want %q", step.name, p.Type, step.wantProblem)
+			}
+			continue
+		}
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status %d, body %s", step.name, rec.Code, rec.Body)
+		}
+		var resp subscriptionResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != step.wantSubStatus {
+			t.Errorf("%s: status = %q, want %q", step.name, resp.Status, step.wantSubStatus)
+		}
+	}
+
+	rec = do(t, srv, http.MethodGet, base, "")
+	var got subscriptionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != storage.StatusEnded || got.EndDate == nil || *got.EndDate != monthParam(-1) {
+		t.Errorf("GET after cancel: status %q, end_date %v, want ended in %s", got.Status, got.EndDate, monthParam(-1))
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/BaikalMine/em-subscription-service/internal/webhooks"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// WebhookHandler обслуживает регистрацию вебхуков и просмотр доставок.
+type WebhookHandler struct {
+	store *webhooks.Store
+}
+
+// NewWebhookHandler создаёт обработчик вебхуков.
+func NewWebhookHandler(store *webhooks.Store) *WebhookHandler {
+	return &WebhookHandler{store: store}
+}
+
+// RegisterRoutes регистрирует маршруты вебхуков на роутере.
+func (h *WebhookHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Get("/", h.listWebhooks)
+		r.Post("/", h.createWebhook)
+		r.Get("/deliveries/{deliveryID}", h.getDelivery)
+		r.Post("/deliveries/{deliveryID}/replay", h.replayDelivery)
+		r.Get("/{id}", h.getWebhook)
+		r.Delete("/{id}", h.deleteWebhook)
+		r.Get("/{id}/deliveries", h.listDeliveries)
+	})
+}
+
+func (h *WebhookHandler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		logHandled(r, err)
+		writeDecodeProblem(w, r, err)
+		return
+	}
+	hook, err := req.toWebhook()
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+	if hook.Secret == "" {
+		if hook.Secret, err = webhooks.NewSecret(); err != nil {
+			logHandled(r, err)
+			writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to generate webhook secret"))
+			return
+		}
+	}
+
+	if err := h.store.Create(r.Context(), hook); err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to persist webhook"))
+		return
+	}
+
+	// Секрет показываем только при создании, дальше он не возвращается.
+	resp := convertWebhook(hook)
+	resp.Secret = hook.Secret
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (h *WebhookHandler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.store.List(r.Context())
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to fetch webhooks"))
+		return
+	}
+
+	resp := make([]webhookResponse, 0, len(hooks))
+	for i := range hooks {
+		resp = append(resp, convertWebhook(&hooks[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *WebhookHandler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	hook, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeProblem(w, r, newProblem(http.StatusNotFound, "webhook not found"))
+			return
+		}
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "failed to load webhook"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, convertWebhook(hook))
+}
+
+func (h *WebhookHandler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeProblem(w, r, newProblem(http.StatusNotFound, "webhook not found"))
+			return
+		}
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to remove webhook"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid id"))
+		return
+	}
+	filter, err := buildDeliveryFilter(r)
+	if err != nil {
+		logHandled(r, err)
+		writeValidationProblem(w, r, err)
+		return
+	}
+	filter.WebhookID = id
+
+	if _, err := h.store.Get(r.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeProblem(w, r, newProblem(http.StatusNotFound, "webhook not found"))
+			return
+		}
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "failed to load webhook"))
+		return
+	}
+
+	deliveries, err := h.store.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to fetch deliveries"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (h *WebhookHandler) getDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid delivery id"))
+		return
+	}
+
+	delivery, err := h.store.GetDelivery(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeProblem(w, r, newProblem(http.StatusNotFound, "delivery not found"))
+			return
+		}
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "failed to load delivery"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+func (h *WebhookHandler) replayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, "invalid delivery id"))
+		return
+	}
+
+	delivery, err := h.store.Replay(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeProblem(w, r, newProblem(http.StatusNotFound, "delivery not found"))
+			return
+		}
+		logHandled(r, err)
+		writeProblem(w, r, newProblem(http.StatusInternalServerError, "unable to replay delivery"))
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+// buildDeliveryFilter формирует фильтры списка доставок из query параметров.
+func buildDeliveryFilter(r *http.Request) (webhooks.DeliveryFilter, error) {
+	var filter webhooks.DeliveryFilter
+	var errs validationErrors
+	query := r.URL.Query()
+	if status := strings.TrimSpace(query.Get("status")); status != "" {
+		switch status {
+		case webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusDead:
+			filter.Status = &status
+		default:
+			errs.add("status", codeUnknownValue, "status must be one of pending, delivered, dead")
+		}
+	}
+	if limit := strings.TrimSpace(query.Get("limit")); limit != "" {
+		val, err := strconv.Atoi(limit)
+		if err != nil {
+			errs.add("limit", codeInvalidFormat, "limit must be an integer")
+		}
+		filter.Limit = val
+	}
+	if offset := strings.TrimSpace(query.Get("offset")); offset != "" {
+		val, err := strconv.Atoi(offset)
+		if err != nil {
+			errs.add("offset", codeInvalidFormat, "offset must be an integer")
+		}
+		filter.Offset = val
+	}
+	return filter, errs.err()
+}
+
+// toWebhook проверяет запрос и переводит его в модель вебхука. Если секрет
+// не задан, он остаётся пустым: его генерирует обработчик.
+func (req *webhookRequest) toWebhook() (*webhooks.Webhook, error) {
+	var errs validationErrors
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		errs.add("url", codeInvalidFormat, "url must be an absolute http(s) URL")
+	}
+	if len(req.EventTypes) == 0 {
+		errs.add("event_types", codeRequired, "event_types must not be empty")
+	}
+	for i, t := range req.EventTypes {
+		if t != webhooks.AllEvents && !events.IsKnown(t) {
+			errs.add(fmt.Sprintf("event_types[%d]", i), codeUnknownValue, fmt.Sprintf("unknown event type %q", t))
+		}
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return &webhooks.Webhook{
+		URL:        target.String(),
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		Active:     true,
+	}, nil
+}
+
+// convertWebhook собирает ответ API из модели вебхука.
+func convertWebhook(hook *webhooks.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:         hook.ID.String(),
+		URL:        hook.URL,
+		EventTypes: hook.EventTypes,
+		Active:     hook.Active,
+		CreatedAt:  hook.CreatedAt,
+	}
+}
+
+type webhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+type webhookResponse struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,125 @@
+// Package health проверяет готовность сервиса принимать запросы: доступность
+// базы, актуальность миграций и то, что сервер не останавливается.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/migrate"
+)
+
+// Check — одна проверка готовности; nil означает, что всё в порядке.
+type Check func(ctx context.Context) error
+
+// Result — итог одной проверки.
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// errShuttingDown — результат проверки shutdown после начала остановки.
+var errShuttingDown = errors.New("server is shutting down")
+
+// Probe хранит проверки готовности и признак остановки сервера.
+type Probe struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	timeout  time.Duration
+	stopping atomic.Bool
+}
+
+// NewProbe создаёт набор проверок; каждая ограничена timeout.
+func NewProbe(timeout time.Duration) *Probe {
+	return &Probe{checks: make(map[string]Check), timeout: timeout}
+}
+
+// Add регистрирует проверку под именем name.
+func (p *Probe) Add(name string, check Check) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.checks[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.checks[name] = check
+}
+
+// Shutdown переводит готовность в отказ: балансировщик перестаёт слать
+// новые запросы, пока сервер дорабатывает текущие.
+func (p *Probe) Shutdown() {
+	p.stopping.Store(true)
+}
+
+// Ready выполняет все проверки параллельно и возвращает их результаты в
+// порядке регистрации; первой идёт проверка shutdown. Сервис готов, если ни
+// одна проверка не вернула ошибку.
+func (p *Probe) Ready(ctx context.Context) ([]Result, bool) {
+	p.mu.RLock()
+	names := append([]string(nil), p.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = p.checks[name]
+	}
+	p.mu.RUnlock()
+
+	results := make([]Result, len(names)+1)
+	results[0] = Result{Name: "shutdown"}
+	if p.stopping.Load() {
+		results[0].Err = errShuttingDown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			results[i+1] = Result{Name: names[i], Err: err, Duration: time.Since(start)}
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		if r.Err != nil {
+			ready = false
+		}
+	}
+	return results, ready
+}
+
+// Database проверяет, что база отвечает.
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Migrations проверяет, что все известные сервису миграции применены. Она
+// только читает таблицу версий и ничего не создаёт.
+func Migrations(migrator *migrate.Migrator) Check {
+	return func(ctx context.Context) error {
+		migrations, err := migrator.Pending(ctx)
+		if err != nil {
+			return fmt.Errorf("read migration status: %w", err)
+		}
+		if len(migrations) > 0 {
+			pending := make([]string, 0, len(migrations))
+			for _, mig := range migrations {
+				pending = append(pending, fmt.Sprintf("%04d_%s", mig.Version, mig.Name))
+			}
+			return fmt.Errorf("%d pending migrations: %v", len(pending), pending)
+		}
+		return nil
+	}
+}
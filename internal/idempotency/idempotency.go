@@ -0,0 +1,70 @@
+// Package idempotency хранит ответы на запросы с заголовком Idempotency-Key,
+// чтобы повторы того же запроса получали сохранённый ответ, а не создавали
+// запись заново.
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Record — ключ идемпотентности и, когда запрос завершён, сохранённый ответ.
+type Record struct {
+	Key         string
+	RequestHash string
+	// StatusCode равен 0, пока первый запрос с этим ключом ещё выполняется.
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Completed сообщает, сохранён ли уже ответ.
+func (r *Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// Store хранит ключи идемпотентности; его реализуют SQLStore и MemoryStore.
+type Store interface {
+	// Reserve занимает ключ за запросом с хешем hash до expiresAt. Если ключ
+	// уже занят и не истёк, возвращается существующая запись, а ключ не меняется.
+	Reserve(ctx context.Context, key, hash string, expiresAt time.Time) (*Record, error)
+	// Complete сохраняет ответ на запрос, занявший ключ.
+	Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	// Release освобождает ключ, ответ на который не сохранён, чтобы запрос можно было повторить.
+	Release(ctx context.Context, key string) error
+	// DeleteExpired удаляет ключи, истёкшие к моменту now.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+var (
+	_ Store = (*SQLStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
+
+// Cleanup раз в interval удаляет истёкшие ключи, пока не отменён ctx.
+func Cleanup(ctx context.Context, store Store, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deleted, err := store.DeleteExpired(ctx, time.Now().UTC())
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.WithError(err).Error("idempotency keys cleanup failed")
+			}
+			continue
+		}
+		if deleted > 0 {
+			logger.WithField("deleted", deleted).Debug("expired idempotency keys removed")
+		}
+	}
+}
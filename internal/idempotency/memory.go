@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore хранит ключи идемпотентности в памяти процесса (DB_DRIVER=memory).
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+// NewMemoryStore создаёт пустое хранилище ключей.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+// Reserve занимает ключ; истёкшая запись с тем же ключом заменяется новой.
+func (m *MemoryStore) Reserve(_ context.Context, key, hash string, expiresAt time.Time) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	if rec, ok := m.records[key]; ok && rec.ExpiresAt.After(now) {
+		existing := *rec
+		return &existing, nil
+	}
+	m.records[key] = &Record{Key: key, RequestHash: hash, CreatedAt: now, ExpiresAt: expiresAt.UTC()}
+	return nil, nil
+}
+
+// Complete сохраняет ответ на запрос, занявший ключ.
+func (m *MemoryStore) Complete(_ context.Context, key string, statusCode int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok {
+		rec.StatusCode = statusCode
+		rec.ContentType = contentType
+		rec.Body = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+// Release удаляет ключ, если ответ на него ещё не сохранён.
+func (m *MemoryStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rec, ok := m.records[key]; ok && !rec.Completed() {
+		delete(m.records, key)
+	}
+	return nil
+}
+
+// DeleteExpired удаляет ключи, истёкшие к моменту now.
+func (m *MemoryStore) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for key, rec := range m.records {
+		if !rec.ExpiresAt.After(now) {
+			delete(m.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
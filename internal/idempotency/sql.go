@@ -0,0 +1,98 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+)
+
+// SQLStore хранит ключи идемпотентности в таблице idempotency_keys.
+type SQLStore struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+// NewSQLStore создаёт SQLStore поверх sql.DB и его диалекта.
+func NewSQLStore(db *sql.DB, dialect database.Dialect) *SQLStore {
+	return &SQLStore{db: db, dialect: dialect}
+}
+
+// Reserve занимает ключ; истёкшая запись с тем же ключом заменяется новой.
+func (s *SQLStore) Reserve(ctx context.Context, key, hash string, expiresAt time.Time) (*Record, error) {
+	now := time.Now().UTC()
+	// Вторая попытка нужна, только если ключ был занят истёкшей записью.
+	for range 2 {
+		res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+			`INSERT INTO idempotency_keys (key, request_hash, created_at, expires_at)
+VALUES ($1, $2, $3, $4) ON CONFLICT (key) DO NOTHING`),
+			key, hash, now, expiresAt.UTC())
+		if err != nil {
+			return nil, err
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if rows == 1 {
+			return nil, nil
+		}
+
+		rec, err := s.get(ctx, key)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.ExpiresAt.After(now) {
+			return rec, nil
+		}
+		if _, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+			`DELETE FROM idempotency_keys WHERE key = $1 AND expires_at <= $2`), key, now); err != nil {
+			return nil, err
+		}
+	}
+	return s.get(ctx, key)
+}
+
+// Complete сохраняет ответ на запрос, занявший ключ.
+func (s *SQLStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE idempotency_keys SET status_code = $2, content_type = $3, response_body = $4 WHERE key = $1`),
+		key, statusCode, contentType, body)
+	return err
+}
+
+// Release удаляет ключ, если ответ на него ещё не сохранён.
+func (s *SQLStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`), key)
+	return err
+}
+
+// DeleteExpired удаляет ключи, истёкшие к моменту now.
+func (s *SQLStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM idempotency_keys WHERE expires_at <= $1`), now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// get загружает запись по ключу.
+func (s *SQLStore) get(ctx context.Context, key string) (*Record, error) {
+	var rec Record
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT key, request_hash, status_code, content_type, response_body, created_at, expires_at
+FROM idempotency_keys WHERE key = $1`), key,
+	).Scan(&rec.Key, &rec.RequestHash, &statusCode, &contentType, &rec.Body, &rec.CreatedAt, &rec.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	rec.StatusCode = int(statusCode.Int64)
+	rec.ContentType = contentType.String
+	return &rec, nil
+}
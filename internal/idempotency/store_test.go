@@ -0,0 +1,181 @@
+package idempotency
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/migrate"
+	"github.com/BaikalMine/em-subscription-service/migrations"
+)
+
+// newSQLiteStore открывает чистую SQLite-базу во временном каталоге и
+// применяет миграции.
+func newSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+	ctx := context.Background()
+	cfg := &config.Config{DBDriver: config.DriverSQLite, SQLitePath: filepath.Join(t.TempDir(), "test.db")}
+	db, dialect, err := database.Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	source, err := migrations.For(cfg.DBDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(db, dialect, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewSQLStore(db, dialect)
+}
+
+// TestStore проверяет переходы ключа Reserve → Complete/Release в обоих
+// хранилищах.
+func TestStore(t *testing.T) {
+	type step struct {
+		op   string // reserve, complete или release
+		hash string
+		ttl  time.Duration
+		// Ожидаемый результат reserve: nil, если ключ занят этим вызовом,
+		// иначе хеш и статус существующей записи.
+		wantReserved bool
+		wantHash     string
+		wantStatus   int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "repeat while in progress",
+			steps: []step{
+				{op: "reserve", hash: "a", ttl: time.Hour, wantReserved: true},
+				{op: "reserve", hash: "a", ttl: time.Hour, wantHash: "a"},
+			},
+		},
+		{
+			name: "replay completed",
+			steps: []step{
+				{op: "reserve", hash: "a", ttl: time.Hour, wantReserved: true},
+				{op: "complete"},
+				{op: "reserve", hash: "a", ttl: time.Hour, wantHash: "a", wantStatus: 201},
+			},
+		},
+		{
+			name: "different request keeps original",
+			steps: []step{
+				{op: "reserve", hash: "a", ttl: time.Hour, wantReserved: true},
+				{op: "complete"},
+				{op: "reserve", hash: "b", ttl: time.Hour, wantHash: "a", wantStatus: 201},
+			},
+		},
+		{
+			name: "release frees key",
+			steps: []step{
+				{op: "reserve", hash: "a", ttl: time.Hour, wantReserved: true},
+				{op: "release"},
+				{op: "reserve", hash: "b", ttl: time.Hour, wantReserved: true},
+			},
+		},
+		{
+			name: "release keeps completed",
+			steps: []step{
+				{op: "reserve", hash: "a", ttl: time.Hour, wantReserved: true},
+				{op: "complete"},
+				{op: "release"},
+				{op: "reserve", hash: "a", ttl: time.Hour, wantHash: "a", wantStatus: 201},
+			},
+		},
+		{
+			name: "expired key is taken over",
+			steps: []step{
+				{op: "reserve", hash: "a", ttl: -time.Second, wantReserved: true},
+				{op: "reserve", hash: "b", ttl: time.Hour, wantReserved: true},
+				{op: "reserve", hash: "a", ttl: time.Hour, wantHash: "b"},
+			},
+		},
+	}
+
+	for name, newStore := range map[string]func(t *testing.T) Store{
+		"sqlite": func(t *testing.T) Store { return newSQLiteStore(t) },
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+	} {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				store := newStore(t)
+				for i, s := range tt.steps {
+					var err error
+					switch s.op {
+					case "reserve":
+						var rec *Record
+						rec, err = store.Reserve(ctx, "key", s.hash, time.Now().Add(s.ttl))
+						if err != nil {
+							break
+						}
+						if s.wantReserved {
+							if rec != nil {
+								t.Fatalf("step %d: Reserve returned %+v, want key reserved", i, rec)
+							}
+							continue
+						}
+						if rec == nil || rec.RequestHash != s.wantHash || rec.StatusCode != s.wantStatus {
+							t.Fatalf("step %d: Reserve = %+v, want hash %q and status %d", i, rec, s.wantHash, s.wantStatus)
+						}
+						if s.wantStatus != 0 && (rec.ContentType != "application/json" || string(rec.Body) != `{"id":1}`) {
+							t.Errorf("step %d: stored response %q %q", i, rec.ContentType, rec.Body)
+						}
+					case "complete":
+						err = store.Complete(ctx, "key", 201, "application/json", []byte(`{"id":1}`))
+					case "release":
+						err = store.Release(ctx, "key")
+					}
+					if err != nil {
+						t.Fatalf("step %d: %s: %v", i, s.op, err)
+					}
+				}
+			})
+		}
+
+		t.Run(name+"/concurrent retries", func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			const workers = 8
+			var wg sync.WaitGroup
+			reserved := make([]bool, workers)
+			errs := make([]error, workers)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var rec *Record
+					rec, errs[i] = store.Reserve(ctx, "key", "a", time.Now().Add(time.Hour))
+					reserved[i] = errs[i] == nil && rec == nil
+				}()
+			}
+			wg.Wait()
+
+			count := 0
+			for i := range workers {
+				if errs[i] != nil {
+					t.Fatalf("Reserve: %v", errs[i])
+				}
+				if reserved[i] {
+					count++
+				}
+			}
+			if count != 1 {
+				t.Errorf("%d concurrent Reserve calls took the key, want 1", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Package logging настраивает логгер сервиса (текст или JSON, маскирование
+// чувствительных полей) и ведёт логгер запроса в его контексте.
+package logging
+
+import (
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// New создаёт логгер с уровнем LOG_LEVEL (неизвестный уровень заменяется на
+// info) и форматом LOG_FORMAT. В обоих форматах значения чувствительных
+// полей маскируются.
+func New(cfg *config.Config) *logrus.Logger {
+	logger := logrus.New()
+	level, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	logger.SetLevel(level)
+
+	var formatter logrus.Formatter = &logrus.TextFormatter{FullTimestamp: true}
+	if cfg.LogFormat == config.LogJSON {
+		formatter = &logrus.JSONFormatter{
+			FieldMap: logrus.FieldMap{logrus.FieldKeyMsg: "message"},
+		}
+	}
+	logger.SetFormatter(&redactingFormatter{next: formatter})
+	return logger
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Заголовки, из которых берутся пользователь и арендатор запроса. Их
+// выставляет шлюз перед сервисом; без них пользователь берётся из user_id в
+// строке запроса, а арендатор не пишется.
+const (
+	HeaderUserID   = "X-User-ID"
+	HeaderTenantID = "X-Tenant-ID"
+)
+
+type contextKey struct{}
+
+// requestLog — логгер запроса и ошибка, которую записал обработчик.
+type requestLog struct {
+	entry *logrus.Entry
+	mu    sync.Mutex
+	err   error
+}
+
+// Middleware кладёт в контекст логгер запроса с request_id, методом, путём,
+// пользователем, арендатором и трейсом, а после ответа пишет одну запись о
+// запросе: info для 1xx–3xx, warn для 4xx и error для 5xx, с ошибкой
+// обработчика, если она есть. Подключается после middleware.RequestID и
+// трассировки.
+func Middleware(logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			fields := logrus.Fields{
+				"request_id":  middleware.GetReqID(r.Context()),
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"remote_addr": r.RemoteAddr,
+			}
+			if user := r.Header.Get(HeaderUserID); user != "" {
+				fields["user_id"] = user
+			} else if user := r.URL.Query().Get("user_id"); user != "" {
+				fields["user_id"] = user
+			}
+			if tenant := r.Header.Get(HeaderTenantID); tenant != "" {
+				fields["tenant_id"] = tenant
+			}
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				fields["trace_id"] = span.TraceID().String()
+				fields["span_id"] = span.SpanID().String()
+			}
+			rl := &requestLog{entry: logger.WithFields(fields)}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ctx := context.WithValue(r.Context(), contextKey{}, rl)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			entry := FromContext(ctx).WithFields(logrus.Fields{
+				"status":      status,
+				"duration_ms": time.Since(start).Milliseconds(),
+				"bytes":       ww.BytesWritten(),
+			})
+			rl.mu.Lock()
+			if rl.err != nil {
+				entry = entry.WithError(rl.err)
+			}
+			rl.mu.Unlock()
+			entry.Log(levelFor(status), "request served")
+		})
+	}
+}
+
+// FromContext возвращает логгер запроса с шаблоном маршрута chi, если он уже
+// известен. Вне запроса возвращается стандартный логгер logrus.
+func FromContext(ctx context.Context) *logrus.Entry {
+	rl, ok := ctx.Value(contextKey{}).(*requestLog)
+	if !ok {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+		return rl.entry.WithField("route", rctx.RoutePattern())
+	}
+	return rl.entry
+}
+
+// SetError запоминает ошибку, с которой обработчик завершил запрос; она
+// попадёт в запись о запросе.
+func SetError(ctx context.Context, err error) {
+	if rl, ok := ctx.Value(contextKey{}).(*requestLog); ok {
+		rl.mu.Lock()
+		rl.err = err
+		rl.mu.Unlock()
+	}
+}
+
+func levelFor(status int) logrus.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logrus.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logrus.WarnLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
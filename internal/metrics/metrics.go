@@ -0,0 +1,200 @@
+// Package metrics собирает метрики Prometheus: HTTP-запросы, пул соединений
+// с базой, длительность операций хранилища, кэш summary и бизнес-показатели.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/cache"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// namespace — префикс имён всех метрик сервиса.
+const namespace = "subscriptions"
+
+// collectTimeout ограничивает запросы к хранилищу при пересчёте бизнес-метрик.
+const collectTimeout = 5 * time.Second
+
+// Metrics — реестр метрик сервиса и инструменты для их записи.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	store    *prometheus.HistogramVec
+}
+
+// New создаёт реестр со стандартными метриками процесса и Go и метриками HTTP
+// и хранилища.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		store: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Duration of subscription store operations by operation and outcome (ok, not_found, rejected, error).",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.latency, m.store,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware считает запросы и их длительность. Маршрут берётся из шаблона
+// chi (например, /subscriptions/{id}), чтобы число серий не зависело от id;
+// запросы без маршрута помечаются как unmatched.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+		m.requests.With(labels).Inc()
+		m.latency.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RegisterDB публикует статистику пула соединений db (sql.DB.Stats).
+func (m *Metrics) RegisterDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// RegisterCache публикует счётчики кэша summary.
+func (m *Metrics) RegisterCache(stats func() cache.Stats) {
+	counter := func(name, help string, value func(cache.Stats) int64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "summary_cache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(value(stats())) })
+	}
+	m.registry.MustRegister(
+		counter("hits_total", "Summary cache hits.", func(s cache.Stats) int64 { return s.Hits }),
+		counter("misses_total", "Summary cache misses.", func(s cache.Stats) int64 { return s.Misses }),
+		counter("errors_total", "Summary cache backend errors.", func(s cache.Stats) int64 { return s.Errors }),
+	)
+}
+
+// RegisterBusiness публикует число действующих подписок и расходы за
+// текущий месяц. Значения обновляет Business.Run раз в interval, а не каждый
+// сбор метрик, поэтому частые запросы /metrics не нагружают базу. С
+// useAggregates они читаются из агрегатов (consistency=eventual).
+func (m *Metrics) RegisterBusiness(repo storage.SubscriptionRepository, logger *logrus.Logger, interval time.Duration, useAggregates bool) *Business {
+	b := &Business{
+		repo:          repo,
+		logger:        logger,
+		interval:      interval,
+		useAggregates: useAggregates,
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active",
+			Help:      "Subscriptions active and not paused in the current month.",
+		}),
+		spend: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "monthly_spend_rubles",
+			Help:      "Total spend of all subscriptions in the current month.",
+		}),
+		updated: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "business_metrics_updated_timestamp_seconds",
+			Help:      "Unix time of the last successful update of the business metrics.",
+		}),
+		now: time.Now,
+	}
+	m.registry.MustRegister(b.active, b.spend, b.updated)
+	return b
+}
+
+// Business периодически пересчитывает бизнес-показатели.
+type Business struct {
+	repo                   storage.SubscriptionRepository
+	logger                 *logrus.Logger
+	interval               time.Duration
+	useAggregates          bool
+	active, spend, updated prometheus.Gauge
+	now                    func() time.Time
+}
+
+// Run обновляет показатели сразу и затем каждые interval, пока не отменён ctx.
+func (b *Business) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			b.logger.WithError(err).Warn("business metrics update failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce считает показатели за текущий месяц через кэш summary и, если
+// включено useAggregates, из агрегатов.
+func (b *Business) RunOnce(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+
+	now := b.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	filter := storage.SummaryFilter{PeriodStart: month, PeriodEnd: month.AddDate(0, 1, -1), AllowStale: b.useAggregates}
+
+	activity, err := b.repo.Activity(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("count active subscriptions: %w", err)
+	}
+	total, err := b.repo.Summary(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("sum monthly spend: %w", err)
+	}
+	if len(activity) > 0 {
+		b.active.Set(float64(activity[0].Active))
+	}
+	b.spend.Set(float64(total))
+	b.updated.Set(float64(now.Unix()))
+	return nil
+}
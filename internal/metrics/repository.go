@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// repository измеряет длительность каждой операции хранилища.
+type repository struct {
+	next     storage.SubscriptionRepository
+	duration *prometheus.HistogramVec
+}
+
+// Repository оборачивает repo замером операций в
+// store_operation_duration_seconds.
+func (m *Metrics) Repository(repo storage.SubscriptionRepository) storage.SubscriptionRepository {
+	return &repository{next: repo, duration: m.store}
+}
+
+func (r *repository) Create(ctx context.Context, sub *storage.Subscription) (err error) {
+	defer r.observe("create", time.Now(), &err)
+	return r.next.Create(ctx, sub)
+}
+
+func (r *repository) Get(ctx context.Context, id uuid.UUID) (_ *storage.Subscription, err error) {
+	defer r.observe("get", time.Now(), &err)
+	return r.next.Get(ctx, id)
+}
+
+func (r *repository) List(ctx context.Context, filter storage.ListFilter) (_ []storage.Subscription, err error) {
+	defer r.observe("list", time.Now(), &err)
+	return r.next.List(ctx, filter)
+}
+
+func (r *repository) Update(ctx context.Context, sub *storage.Subscription) (err error) {
+	defer r.observe("update", time.Now(), &err)
+	return r.next.Update(ctx, sub)
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	defer r.observe("delete", time.Now(), &err)
+	return r.next.Delete(ctx, id)
+}
+
+func (r *repository) Summary(ctx context.Context, filter storage.SummaryFilter) (_ int, err error) {
+	defer r.observe("summary", time.Now(), &err)
+	return r.next.Summary(ctx, filter)
+}
+
+func (r *repository) Forecast(ctx context.Context, filter storage.SummaryFilter) (_ []storage.MonthlyCost, err error) {
+	defer r.observe("forecast", time.Now(), &err)
+	return r.next.Forecast(ctx, filter)
+}
+
+func (r *repository) Activity(ctx context.Context, filter storage.SummaryFilter) (_ []storage.Activity, err error) {
+	defer r.observe("activity", time.Now(), &err)
+	return r.next.Activity(ctx, filter)
+}
+
+func (r *repository) ServicePrices(ctx context.Context, filter storage.SummaryFilter) (_ []storage.ServicePrice, err error) {
+	defer r.observe("service_prices", time.Now(), &err)
+	return r.next.ServicePrices(ctx, filter)
+}
+
+func (r *repository) Lifetime(ctx context.Context, filter storage.SummaryFilter) (_ *storage.Lifetime, err error) {
+	defer r.observe("lifetime", time.Now(), &err)
+	return r.next.Lifetime(ctx, filter)
+}
+
+func (r *repository) Overlapping(ctx context.Context, sub *storage.Subscription) (_ []storage.Subscription, err error) {
+	defer r.observe("overlapping", time.Now(), &err)
+	return r.next.Overlapping(ctx, sub)
+}
+
+func (r *repository) Overlaps(ctx context.Context, filter storage.OverlapFilter) (_ []storage.Overlap, err error) {
+	defer r.observe("overlaps", time.Now(), &err)
+	return r.next.Overlaps(ctx, filter)
+}
+
+func (r *repository) Cancel(ctx context.Context, id uuid.UUID, month time.Time) (_ *storage.Subscription, err error) {
+	defer r.observe("cancel", time.Now(), &err)
+	return r.next.Cancel(ctx, id, month)
+}
+
+func (r *repository) Pause(ctx context.Context, id uuid.UUID, pause storage.Pause) (_ *storage.Subscription, err error) {
+	defer r.observe("pause", time.Now(), &err)
+	return r.next.Pause(ctx, id, pause)
+}
+
+func (r *repository) Resume(ctx context.Context, id uuid.UUID, month time.Time) (_ *storage.Subscription, err error) {
+	defer r.observe("resume", time.Now(), &err)
+	return r.next.Resume(ctx, id, month)
+}
+
+func (r *repository) observe(operation string, start time.Time, err *error) {
+	r.duration.WithLabelValues(operation, outcome(*err)).Observe(time.Since(start).Seconds())
+}
+
+// outcome отделяет ожидаемые отказы хранилища (нет записи, нарушено
+// ограничение или состояние, пересечение в режиме reject) от сбоев, чтобы по
+// error можно было алертить.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, storage.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, storage.ErrDuplicate), errors.Is(err, storage.ErrConstraint),
+		errors.Is(err, storage.ErrConflict), errors.Is(err, storage.ErrInvalidState),
+		errors.Is(err, storage.ErrOverlap):
+		return "rejected"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Message — уведомление, которое доставляет Notifier.
+type Message struct {
+	Kind    string         `json:"kind"`
+	Subject string         `json:"subject"`
+	Body    string         `json:"body"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// Notifier доставляет уведомления по конкретному каналу.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Retry оборачивает Notifier повторными попытками с экспоненциальной задержкой.
+func Retry(next Notifier, attempts int, backoff time.Duration) Notifier {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &retryNotifier{next: next, attempts: attempts, backoff: backoff}
+}
+
+type retryNotifier struct {
+	next     Notifier
+	attempts int
+	backoff  time.Duration
+}
+
+func (r *retryNotifier) Notify(ctx context.Context, msg Message) error {
+	delay := r.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = r.next.Notify(ctx, msg); err == nil || attempt == r.attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
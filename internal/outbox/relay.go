@@ -0,0 +1,139 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+const batchSize = 100
+
+// RelayConfig задаёт параметры ретранслятора.
+type RelayConfig struct {
+	PollInterval time.Duration
+	// Retention — сколько хранить опубликованные события; 0 — не удалять.
+	Retention time.Duration
+}
+
+// Relay читает неопубликованные события из таблицы outbox и передаёт их в sink.
+// Событие помечается опубликованным только после успешной передачи, поэтому
+// доставка гарантируется «как минимум один раз», а потребители дедуплицируют по id.
+type Relay struct {
+	db      *sql.DB
+	dialect database.Dialect
+	sink    events.Publisher
+	logger  *logrus.Logger
+	cfg     RelayConfig
+}
+
+// NewRelay создаёт ретранслятор outbox.
+func NewRelay(db *sql.DB, dialect database.Dialect, sink events.Publisher, logger *logrus.Logger, cfg RelayConfig) *Relay {
+	return &Relay{db: db, dialect: dialect, sink: sink, logger: logger, cfg: cfg}
+}
+
+// Run ретранслирует события каждые PollInterval, пока не отменён ctx.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			published, err := r.RunOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.logger.WithError(err).Error("outbox relay failed")
+			}
+			if err != nil || published < batchSize {
+				break
+			}
+		}
+		if err := r.cleanup(ctx); err != nil && ctx.Err() == nil {
+			r.logger.WithError(err).Error("outbox cleanup failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce публикует одну пачку событий по порядку и возвращает число опубликованных.
+// Первая ошибка останавливает пачку, чтобы не нарушать порядок событий.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, event_type, data, occurred_at FROM outbox
+WHERE published_at IS NULL
+ORDER BY occurred_at
+LIMIT $1`
+	// SKIP LOCKED позволяет нескольким экземплярам работать параллельно, не мешая друг другу.
+	// В SQLite транзакция и так держит блокировку записи на всю базу.
+	if r.dialect == database.Postgres {
+		query += "\nFOR UPDATE SKIP LOCKED"
+	}
+	rows, err := tx.QueryContext(ctx, r.dialect.Rebind(query), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	var pending []events.Event
+	for rows.Next() {
+		var event events.Event
+		var data []byte
+		if err := rows.Scan(&event.ID, &event.Type, &data, &event.OccurredAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		event.Data = json.RawMessage(data)
+		pending = append(pending, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for _, event := range pending {
+		if publishErr = r.sink.Publish(ctx, event); publishErr != nil {
+			if _, err := tx.ExecContext(ctx, r.dialect.Rebind(
+				`UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`),
+				event.ID, publishErr.Error()); err != nil {
+				return 0, err
+			}
+			publishErr = fmt.Errorf("publish event %s: %w", event.ID, publishErr)
+			break
+		}
+		if _, err := tx.ExecContext(ctx, r.dialect.Rebind(
+			`UPDATE outbox SET published_at = $2, attempts = attempts + 1, last_error = NULL WHERE id = $1`),
+			event.ID, time.Now().UTC()); err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
+
+// cleanup удаляет опубликованные события старше Retention.
+func (r *Relay) cleanup(ctx context.Context) error {
+	if r.cfg.Retention <= 0 {
+		return nil
+	}
+	_, err := r.db.ExecContext(ctx, r.dialect.Rebind(
+		`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`),
+		time.Now().UTC().Add(-r.cfg.Retention))
+	return err
+}
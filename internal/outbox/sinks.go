@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/sirupsen/logrus"
+)
+
+// Broker — минимальный адаптер к брокеру сообщений (Kafka, NATS и т.п.).
+// Реализация отправляет value в topic (subject) с ключом партиционирования key.
+type Broker interface {
+	Send(ctx context.Context, topic string, key, value []byte) error
+}
+
+// BrokerSink публикует события в брокер: топик — prefix + тип события,
+// ключ — id события.
+type BrokerSink struct {
+	broker Broker
+	prefix string
+}
+
+// NewBrokerSink создаёт sink поверх адаптера брокера.
+func NewBrokerSink(broker Broker, prefix string) *BrokerSink {
+	return &BrokerSink{broker: broker, prefix: prefix}
+}
+
+// Publish сериализует событие и отправляет его в брокер.
+func (s *BrokerSink) Publish(ctx context.Context, event events.Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.broker.Send(ctx, s.prefix+event.Type, []byte(event.ID.String()), value)
+}
+
+// MemorySink складывает события в память; используется в тестах и локально.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+// NewMemorySink создаёт пустой MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Publish запоминает событие.
+func (s *MemorySink) Publish(_ context.Context, event events.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// Events возвращает копию полученных событий в порядке публикации.
+func (s *MemorySink) Events() []events.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]events.Event(nil), s.events...)
+}
+
+// LogSink пишет события в лог на уровне Debug.
+type LogSink struct {
+	logger *logrus.Logger
+}
+
+// NewLogSink создаёт sink, логирующий события.
+func NewLogSink(logger *logrus.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// Publish логирует событие.
+func (s *LogSink) Publish(_ context.Context, event events.Event) error {
+	s.logger.WithFields(logrus.Fields{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+	}).Debug("domain event published")
+	return nil
+}
+
+// Fanout передаёт событие во все sinks и возвращает объединённую ошибку.
+// Sinks должны быть идемпотентны: при ошибке одного из них событие будет
+// отправлено повторно во все.
+type Fanout []events.Publisher
+
+// Publish передаёт событие каждому sink.
+func (f Fanout) Publish(ctx context.Context, event events.Event) error {
+	var errs []error
+	for _, sink := range f {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
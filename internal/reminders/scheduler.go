@@ -0,0 +1,213 @@
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/notify"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// KindUpcomingCharge — напоминание о списании в начале следующего месяца.
+	KindUpcomingCharge = "upcoming_charge"
+	// KindEnding — напоминание о том, что подписка заканчивается.
+	KindEnding = "ending"
+
+	pageSize = 500
+)
+
+// Source отдаёт подписки по фильтру; его реализует storage.Store.
+type Source interface {
+	List(ctx context.Context, filter storage.ListFilter) ([]storage.Subscription, error)
+}
+
+// Scheduler периодически ищет подписки с близкими списаниями или окончанием
+// и рассылает по ним напоминания, не отправляя одно и то же дважды.
+type Scheduler struct {
+	source   Source
+	journal  *Journal
+	notifier notify.Notifier
+	logger   *logrus.Logger
+	interval time.Duration
+	lead     time.Duration
+	now      func() time.Time
+}
+
+// NewScheduler создаёт планировщик; lead задаёт, за сколько до списания напоминать.
+func NewScheduler(source Source, journal *Journal, notifier notify.Notifier, logger *logrus.Logger, interval, lead time.Duration) *Scheduler {
+	return &Scheduler{
+		source:   source,
+		journal:  journal,
+		notifier: notifier,
+		logger:   logger,
+		interval: interval,
+		lead:     lead,
+		now:      time.Now,
+	}
+}
+
+// Run выполняет проверки каждые interval, пока не отменён ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).Error("reminder run failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce рассылает напоминания, если до ближайшего списания осталось не больше lead.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	now := s.now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	next := current.AddDate(0, 1, 0)
+	if now.Add(s.lead).Before(next) {
+		return nil
+	}
+
+	charges, err := s.collect(ctx, storage.ListFilter{ActiveIn: &next})
+	if err != nil {
+		return fmt.Errorf("load upcoming charges: %w", err)
+	}
+	endings, err := s.collect(ctx, storage.ListFilter{EndsIn: &current})
+	if err != nil {
+		return fmt.Errorf("load ending subscriptions: %w", err)
+	}
+
+	for i := range charges {
+		if !charged(&charges[i], next) {
+			continue
+		}
+		s.remind(ctx, KindUpcomingCharge, next, &charges[i])
+	}
+	for i := range endings {
+		s.remind(ctx, KindEnding, next, &endings[i])
+	}
+	return nil
+}
+
+// charged сообщает, будет ли списание по подписке в месяце month: он не
+// приостановлен, на него выпадает оплата по billing_months и цена месяца не
+// нулевая (пробный период).
+func charged(sub *storage.Subscription, month time.Time) bool {
+	return !sub.PausedIn(month) && sub.DueIn(month) && sub.PriceIn(month) > 0
+}
+
+// collect постранично читает все подписки, подходящие под фильтр.
+func (s *Scheduler) collect(ctx context.Context, filter storage.ListFilter) ([]storage.Subscription, error) {
+	var result []storage.Subscription
+	filter.Limit = pageSize
+	for {
+		page, err := s.source.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if len(page) < pageSize {
+			return result, nil
+		}
+		filter.Offset += pageSize
+	}
+}
+
+// remind резервирует запись в журнале и отправляет уведомление; при ошибке
+// отправки резерв снимается, чтобы следующий запуск повторил попытку.
+func (s *Scheduler) remind(ctx context.Context, kind string, due time.Time, sub *storage.Subscription) {
+	log := s.logger.WithFields(logrus.Fields{
+		"subscription_id": sub.ID,
+		"kind":            kind,
+		"due_date":        due.Format("2006-01-02"),
+	})
+
+	reserved, err := s.journal.Reserve(ctx, sub.ID, kind, due)
+	if err != nil {
+		log.WithError(err).Error("failed to reserve reminder")
+		return
+	}
+	if !reserved {
+		return
+	}
+
+	if err := s.notifier.Notify(ctx, message(kind, due, sub)); err != nil {
+		log.WithError(err).Warn("failed to send reminder")
+		if err := s.journal.Release(context.WithoutCancel(ctx), sub.ID, kind, due); err != nil {
+			log.WithError(err).Error("failed to release reminder reservation")
+		}
+		return
+	}
+	log.Debug("reminder sent")
+}
+
+// message формирует текст напоминания.
+func message(kind string, due time.Time, sub *storage.Subscription) notify.Message {
+	msg := notify.Message{
+		Kind: kind,
+		Data: map[string]any{
+			"subscription_id": sub.ID.String(),
+			"user_id":         sub.UserID.String(),
+			"service_name":    sub.ServiceName,
+			"price":           sub.Price,
+			"due_date":        due.Format("2006-01-02"),
+		},
+	}
+	switch kind {
+	case KindEnding:
+		msg.Subject = fmt.Sprintf("Подписка %s заканчивается", sub.ServiceName)
+		msg.Body = fmt.Sprintf("Подписка %s пользователя %s действует до %s и не будет продлена.",
+			sub.ServiceName, sub.UserID, due.AddDate(0, 0, -1).Format("02.01.2006"))
+	default:
+		price := sub.PriceIn(due)
+		msg.Data["price"] = price
+		msg.Subject = fmt.Sprintf("Скоро списание за %s", sub.ServiceName)
+		msg.Body = fmt.Sprintf("%s будет списано %d ₽ за подписку %s пользователя %s.",
+			due.Format("02.01.2006"), price, sub.ServiceName, sub.UserID)
+	}
+	return msg
+}
+
+// Journal хранит отправленные напоминания в таблице reminder_log.
+type Journal struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+// NewJournal создаёт журнал напоминаний поверх sql.DB.
+func NewJournal(db *sql.DB, dialect database.Dialect) *Journal {
+	return &Journal{db: db, dialect: dialect}
+}
+
+// Reserve отмечает напоминание как отправляемое; false — его уже отправили.
+func (j *Journal) Reserve(ctx context.Context, subscriptionID uuid.UUID, kind string, due time.Time) (bool, error) {
+	res, err := j.db.ExecContext(ctx, j.dialect.Rebind(
+		`INSERT INTO reminder_log (subscription_id, kind, due_date, sent_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`),
+		subscriptionID, kind, due, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Release снимает резерв, если уведомление не удалось доставить.
+func (j *Journal) Release(ctx context.Context, subscriptionID uuid.UUID, kind string, due time.Time) error {
+	_, err := j.db.ExecContext(ctx, j.dialect.Rebind(
+		`DELETE FROM reminder_log WHERE subscription_id = $1 AND kind = $2 AND due_date = $3`),
+		subscriptionID, kind, due)
+	return err
+}
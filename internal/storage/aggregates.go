@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AggregateWindow — месяцы, за которые посчитаны агрегаты, и время пересчёта.
+type AggregateWindow struct {
+	From        time.Time
+	To          time.Time
+	RefreshedAt time.Time
+}
+
+// covers сообщает, попадают ли все месяцы периода [from, to] в окно.
+func (w *AggregateWindow) covers(from, to time.Time) bool {
+	return !monthOf(from).Before(w.From) && !monthOf(to).After(w.To)
+}
+
+// UseAggregates разрешает отвечать на запросы с AllowStale из агрегатов, если
+// они пересчитаны не раньше maxAge назад; иначе, как и при maxAge = 0 (по
+// умолчанию), сумма считается по подпискам. Вызывается до начала работы.
+func (s *Store) UseAggregates(maxAge time.Duration) {
+	s.aggregateMaxAge = maxAge
+}
+
+// aggregatesFor сообщает, можно ли ответить на запрос filter из агрегатов:
+// они разрешены, достаточно свежие и покрывают его период.
+func (s *Store) aggregatesFor(ctx context.Context, filter SummaryFilter) (bool, error) {
+	if s.aggregateMaxAge <= 0 {
+		return false, nil
+	}
+	window, err := s.AggregateWindow(ctx)
+	if err != nil || window == nil {
+		return false, err
+	}
+	if time.Since(window.RefreshedAt) > s.aggregateMaxAge {
+		return false, nil
+	}
+	return window.covers(filter.PeriodStart, filter.PeriodEnd), nil
+}
+
+// RefreshAggregates пересчитывает помесячные агрегаты по пользователям и
+// сервисам за месяцы [from, to]. Каждый месяц считается в базе и заменяется
+// в своей короткой транзакции, чтобы не держать блокировку записи на весь
+// пересчёт; затем удаляются строки вне окна и запоминается новое окно.
+func (s *Store) RefreshAggregates(ctx context.Context, from, to time.Time) error {
+	from, to = monthOf(from), monthOf(to)
+	if to.Before(from) {
+		return fmt.Errorf("aggregate window ends before it starts")
+	}
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		if err := s.refreshAggregateMonth(ctx, month); err != nil {
+			return fmt.Errorf("refresh aggregates for %s: %w", formatMonth(month), err)
+		}
+	}
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`DELETE FROM subscription_aggregates WHERE month < $1 OR month > $2`), from, to); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`INSERT INTO aggregate_refreshes (id, from_month, to_month, refreshed_at) VALUES (1, $1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET from_month = excluded.from_month, to_month = excluded.to_month, refreshed_at = excluded.refreshed_at`),
+			from, to, time.Now().UTC())
+		return err
+	})
+}
+
+// refreshAggregateMonth заменяет агрегаты за месяц month. Владелец получает
+// строку с is_owner и нераспределённой долей, каждый участник совместной
+// подписки — свою строку со своей долей; начисление месяца считается так же,
+// как в Summary.
+func (s *Store) refreshAggregateMonth(ctx context.Context, month time.Time) error {
+	where := `deleted_at IS NULL AND start_date <= $1 AND (end_date IS NULL OR end_date >= $2)`
+	idx := monthNumber(month)
+	query := s.chargesQuery(where, 3, 4) + `INSERT INTO subscription_aggregates
+	(month, user_id, service_name, category, is_owner, cost, share_cost, active, started, ended)
+SELECT ` + s.dateParam(5) + `, user_id, service_name, category, is_owner,
+	SUM(cost), SUM((cost * share + 50) / 100), SUM(1 - paused), SUM(started), SUM(ended)
+FROM (
+	SELECT c.user_id, c.service_name, COALESCE(c.category, '') AS category, TRUE AS is_owner, c.cost,
+		100 - COALESCE((SELECT SUM(sm.share) FROM subscription_members sm WHERE sm.subscription_id = c.id), 0) AS share,
+		c.paused,
+		CASE WHEN c.idx = c.start_idx THEN 1 ELSE 0 END AS started,
+		CASE WHEN c.idx = c.end_idx THEN 1 ELSE 0 END AS ended
+	FROM charges c
+	UNION ALL
+	SELECT sm.user_id, c.service_name, COALESCE(c.category, ''), FALSE, c.cost, sm.share, c.paused,
+		CASE WHEN c.idx = c.start_idx THEN 1 ELSE 0 END,
+		CASE WHEN c.idx = c.end_idx THEN 1 ELSE 0 END
+	FROM charges c JOIN subscription_members sm ON sm.subscription_id = c.id
+) shares
+GROUP BY user_id, service_name, category, is_owner`
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`DELETE FROM subscription_aggregates WHERE month = $1`), month); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, s.dialect.Rebind(query), month.AddDate(0, 1, -1), month, idx, idx, month)
+		return err
+	})
+}
+
+// AggregateWindow возвращает окно последнего пересчёта агрегатов или nil,
+// если их ещё не считали.
+func (s *Store) AggregateWindow(ctx context.Context) (*AggregateWindow, error) {
+	var w AggregateWindow
+	err := s.db.QueryRowContext(ctx, `SELECT from_month, to_month, refreshed_at FROM aggregate_refreshes WHERE id = 1`).
+		Scan(&w.From, &w.To, &w.RefreshedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translate(err)
+	}
+	w.From, w.To = monthOf(w.From), monthOf(w.To)
+	return &w, nil
+}
+
+// aggregateWhere возвращает условие по фильтрам Summary для таблицы
+// subscription_aggregates, начиная нумерацию аргументов после args. С
+// фильтром по пользователю берутся его строки владельца и участника, без
+// него — только строки владельцев, чтобы каждая подписка считалась один раз.
+func (s *Store) aggregateWhere(filter SummaryFilter, args []any) (string, []any) {
+	var where string
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where = fmt.Sprintf("user_id = $%d", len(args))
+	} else {
+		args = append(args, true)
+		where = fmt.Sprintf("is_owner = $%d", len(args))
+	}
+	if filter.ServiceName != nil {
+		args = append(args, *filter.ServiceName)
+		where += " AND " + s.ilike("service_name", len(args))
+	}
+	if filter.Category != nil {
+		args = append(args, *filter.Category)
+		where += fmt.Sprintf(" AND %s = %s", s.fold("category"), s.fold(fmt.Sprintf("CAST($%d AS TEXT)", len(args))))
+	}
+	return where, args
+}
+
+// aggregatedSummary считает Summary по агрегатам как сумму строк за месяцы
+// периода; false — агрегаты нельзя использовать (см. aggregatesFor) и
+// считать нужно по подпискам.
+func (s *Store) aggregatedSummary(ctx context.Context, filter SummaryFilter) (int, bool, error) {
+	ok, err := s.aggregatesFor(ctx, filter)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	column := "cost"
+	if filter.UserID != nil {
+		column = "share_cost"
+	}
+	where, args := s.aggregateWhere(filter, []any{monthOf(filter.PeriodStart), monthOf(filter.PeriodEnd)})
+	var total int
+	err = s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT COALESCE(SUM(`+column+`), 0) FROM subscription_aggregates WHERE month >= $1 AND month <= $2 AND `+where),
+		args...).Scan(&total)
+	if err != nil {
+		return 0, false, translate(err)
+	}
+	return total, true, nil
+}
+
+// aggregatedActivity считает Activity по агрегатам; false — агрегаты нельзя
+// использовать, см. aggregatesFor.
+func (s *Store) aggregatedActivity(ctx context.Context, filter SummaryFilter) ([]Activity, bool, error) {
+	ok, err := s.aggregatesFor(ctx, filter)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	result := make([]Activity, 0)
+	index := make(map[time.Time]int)
+	for month := monthOf(filter.PeriodStart); !month.After(filter.PeriodEnd); month = month.AddDate(0, 1, 0) {
+		index[month] = len(result)
+		result = append(result, Activity{Month: month})
+	}
+
+	where, args := s.aggregateWhere(filter, []any{monthOf(filter.PeriodStart), monthOf(filter.PeriodEnd)})
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(
+		`SELECT month, SUM(active), SUM(started), SUM(ended) FROM subscription_aggregates
+WHERE month >= $1 AND month <= $2 AND `+where+` GROUP BY month`), args...)
+	if err != nil {
+		return nil, false, translate(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Activity
+		if err := rows.Scan(&a.Month, &a.Active, &a.New, &a.Ended); err != nil {
+			return nil, false, err
+		}
+		a.Month = monthOf(a.Month)
+		if i, ok := index[a.Month]; ok {
+			result[i] = a
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, translate(err)
+	}
+	return result, true, nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Activity — число подписок по месяцам.
+type Activity struct {
+	Month time.Time
+	// Active — подписки, действующие в месяце и не приостановленные.
+	Active int
+	// New — подписки с start_date в этом месяце.
+	New int
+	// Ended — подписки, у которых этот месяц последний оплаченный.
+	Ended int
+}
+
+// ServicePrice — статистика полной цены подписок одного сервиса.
+type ServicePrice struct {
+	ServiceName   string
+	Subscriptions int
+	AveragePrice  float64
+	MedianPrice   float64
+}
+
+// Lifetime — средняя продолжительность подписок в месяцах.
+type Lifetime struct {
+	Subscriptions int
+	// AverageMonths считается от start_date до end_date или конца периода,
+	// если подписка длится дольше.
+	AverageMonths float64
+	// Ended и AverageEndedMonths — то же только по подпискам, закончившимся
+	// не позже конца периода.
+	Ended              int
+	AverageEndedMonths float64
+}
+
+// Activity считает по каждому месяцу периода действующие, новые и
+// закончившиеся подписки, попадающие в фильтры Summary. С AllowStale
+// счётчики берутся из агрегатов, если они покрывают период.
+func (s *Store) Activity(ctx context.Context, filter SummaryFilter) ([]Activity, error) {
+	if filter.AllowStale {
+		if result, ok, err := s.aggregatedActivity(ctx, filter); err != nil || ok {
+			return result, err
+		}
+	}
+	where, args := s.summaryWhere(filter)
+	result := make([]Activity, 0)
+	months := make([]string, 0)
+	for month := monthOf(filter.PeriodStart); !month.After(filter.PeriodEnd); month = month.AddDate(0, 1, 0) {
+		args = append(args, month, month.AddDate(0, 1, -1))
+		months = append(months, fmt.Sprintf("SELECT %d AS idx, %s AS month_start, %s AS month_end",
+			len(result), s.dateParam(len(args)-1), s.dateParam(len(args))))
+		result = append(result, Activity{Month: month})
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	query := `WITH months AS (` + strings.Join(months, " UNION ALL ") + `)
+SELECT m.idx,
+	COUNT(CASE WHEN s.start_date <= m.month_end AND (s.end_date IS NULL OR s.end_date >= m.month_start)
+		AND NOT EXISTS (SELECT 1 FROM subscription_pauses p WHERE p.subscription_id = s.id
+			AND p.start_month <= m.month_start AND (p.end_month IS NULL OR p.end_month >= m.month_start)) THEN 1 END),
+	COUNT(CASE WHEN s.start_date >= m.month_start AND s.start_date <= m.month_end THEN 1 END),
+	COUNT(CASE WHEN s.end_date >= m.month_start AND s.end_date <= m.month_end THEN 1 END)
+FROM months m LEFT JOIN (SELECT id, start_date, end_date FROM subscriptions WHERE ` + where + `) s ON TRUE
+GROUP BY m.idx ORDER BY m.idx`
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idx int
+		var a Activity
+		if err := rows.Scan(&idx, &a.Active, &a.New, &a.Ended); err != nil {
+			return nil, err
+		}
+		a.Month = result[idx].Month
+		result[idx] = a
+	}
+	return result, translate(rows.Err())
+}
+
+// ServicePrices считает среднюю и медианную полную цену (price, без учёта
+// пробного периода и промо-цен) по сервисам; названия сравниваются без
+// учёта регистра.
+func (s *Store) ServicePrices(ctx context.Context, filter SummaryFilter) ([]ServicePrice, error) {
+	where, args := s.summaryWhere(filter)
+	key := s.fold("service_name")
+	query := `WITH ranked AS (
+	SELECT service_name, ` + key + ` AS service_key, price,
+		ROW_NUMBER() OVER (PARTITION BY ` + key + ` ORDER BY price) AS rank_no,
+		COUNT(*) OVER (PARTITION BY ` + key + `) AS total
+	FROM subscriptions WHERE ` + where + `
+)
+SELECT MIN(service_name), COUNT(*), AVG(price),
+	AVG(CASE WHEN rank_no IN ((total + 1) / 2, (total + 2) / 2) THEN price END)
+FROM ranked GROUP BY service_key ORDER BY service_key`
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	result := make([]ServicePrice, 0)
+	for rows.Next() {
+		var p ServicePrice
+		if err := rows.Scan(&p.ServiceName, &p.Subscriptions, &p.AveragePrice, &p.MedianPrice); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, translate(rows.Err())
+}
+
+// Lifetime считает среднюю продолжительность подписок, попадающих в фильтры
+// Summary, в календарных месяцах включительно.
+func (s *Store) Lifetime(ctx context.Context, filter SummaryFilter) (*Lifetime, error) {
+	where, args := s.summaryWhere(filter)
+	last := `CASE WHEN end_date IS NOT NULL AND end_date < $1 THEN end_date ELSE ` + s.dateParam(1) + ` END`
+	query := `SELECT COUNT(*), AVG(months), COUNT(CASE WHEN ended = 1 THEN 1 END), AVG(CASE WHEN ended = 1 THEN months END)
+FROM (
+	SELECT ` + s.monthIndex(last) + ` - ` + s.monthIndex("start_date") + ` + 1 AS months,
+		CASE WHEN end_date IS NOT NULL AND end_date <= $1 THEN 1 ELSE 0 END AS ended
+	FROM subscriptions WHERE ` + where + `
+) lifetimes`
+
+	var l Lifetime
+	var average, averageEnded sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, s.dialect.Rebind(query), args...).
+		Scan(&l.Subscriptions, &average, &l.Ended, &averageEnded)
+	if err != nil {
+		return nil, translate(err)
+	}
+	l.AverageMonths = average.Float64
+	l.AverageEndedMonths = averageEnded.Float64
+	return &l, nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// monthNumber возвращает порядковый номер месяца t (год * 12 + месяц) — то же,
+// что monthIndex в SQL.
+func monthNumber(t time.Time) int {
+	return t.Year()*12 + int(t.Month())
+}
+
+// chargesQuery возвращает CTE с помесячными начислениями подписок, попавших
+// под условие where на таблицу subscriptions, за месяцы с номерами от $from
+// до $to. CTE charges содержит по строке на каждый месяц срока подписки в
+// этом интервале: id, user_id, service_name, category, idx (номер месяца),
+// start_idx, end_idx, paused, charged (1 — месяц оплачивается: не
+// приостановлен и выпадает по billing_months) и cost — цену месяца так же,
+// как PriceIn, или 0, если месяц не оплачивается.
+func (s *Store) chargesQuery(where string, from, to int) string {
+	pausedIn := `EXISTS (SELECT 1 FROM subscription_pauses p WHERE p.subscription_id = t.id
+			AND ` + s.monthIndex("p.start_month") + ` <= m.idx
+			AND (p.end_month IS NULL OR ` + s.monthIndex("p.end_month") + ` >= m.idx))`
+	return fmt.Sprintf(`WITH RECURSIVE months (idx) AS (
+	SELECT CAST($%[1]d AS INTEGER)
+	UNION ALL
+	SELECT idx + 1 FROM months WHERE idx < CAST($%[2]d AS INTEGER)
+),
+promo_steps AS (
+	SELECT subscription_id, price, months,
+		SUM(months) OVER (PARTITION BY subscription_id ORDER BY position) AS next_k
+	FROM subscription_promotions
+),
+terms AS (
+	SELECT id, user_id, service_name, category, price, trial_months, billing_months,
+		%[3]s AS start_idx,
+		CASE WHEN end_date IS NULL THEN NULL ELSE %[4]s END AS end_idx
+	FROM subscriptions WHERE %[5]s
+),
+term_months AS (
+	SELECT t.id, t.user_id, t.service_name, t.category, t.price, t.trial_months, t.billing_months,
+		t.start_idx, t.end_idx, m.idx, m.idx - t.start_idx AS k,
+		CASE WHEN %[6]s THEN 1 ELSE 0 END AS paused
+	FROM terms t
+	JOIN months m ON m.idx >= t.start_idx AND (t.end_idx IS NULL OR m.idx <= t.end_idx)
+),
+charges AS (
+	SELECT c.id, c.user_id, c.service_name, c.category, c.idx, c.start_idx, c.end_idx, c.paused,
+		CASE WHEN c.paused = 1 OR c.k %% c.billing_months <> 0 THEN 0 ELSE 1 END AS charged,
+		CASE
+			WHEN c.paused = 1 OR c.k %% c.billing_months <> 0 OR c.k < c.trial_months THEN 0
+			ELSE COALESCE(
+				(SELECT ps.price FROM promo_steps ps WHERE ps.subscription_id = c.id
+					AND c.k - c.trial_months >= ps.next_k - ps.months AND c.k - c.trial_months < ps.next_k),
+				(SELECT pc.price FROM subscription_price_changes pc WHERE pc.subscription_id = c.id
+					AND %[7]s <= c.idx ORDER BY pc.effective_month DESC LIMIT 1),
+				c.price)
+		END AS cost
+	FROM term_months c
+)
+`, from, to, s.monthIndex("start_date"), s.monthIndex("end_date"), where, pausedIn, s.monthIndex("pc.effective_month"))
+}
+
+// shareExpr возвращает выражение с долей пользователя $user в процентах для
+// строки c из charges — так же, как ShareOf.
+func shareExpr(user int) string {
+	return fmt.Sprintf(`COALESCE(
+	(SELECT sm.share FROM subscription_members sm WHERE sm.subscription_id = c.id AND sm.user_id = $%[1]d),
+	CASE WHEN c.user_id = $%[1]d
+		THEN 100 - COALESCE((SELECT SUM(sm.share) FROM subscription_members sm WHERE sm.subscription_id = c.id), 0)
+		ELSE 0 END)`, user)
+}
+
+// monthlyCharges считает начисления по месяцам периода filter: сумму и
+// число оплачиваемых подписок в каждом месяце, в котором они есть. С
+// фильтром по пользователю берётся его доля с округлением помесячно.
+func (s *Store) monthlyCharges(ctx context.Context, filter SummaryFilter) (map[int]MonthlyCost, error) {
+	where, args := s.summaryWhere(filter)
+	args = append(args, monthNumber(filter.PeriodStart), monthNumber(filter.PeriodEnd))
+	query := s.chargesQuery(where, len(args)-1, len(args))
+	cost := "c.cost"
+	if filter.UserID != nil {
+		cost = "(c.cost * " + shareExpr(3) + " + 50) / 100"
+	}
+	query += `SELECT c.idx, COALESCE(SUM(` + cost + `), 0), COALESCE(SUM(c.charged), 0)
+FROM charges c GROUP BY c.idx`
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	result := make(map[int]MonthlyCost)
+	for rows.Next() {
+		var idx int
+		var m MonthlyCost
+		if err := rows.Scan(&idx, &m.Total, &m.Subscriptions); err != nil {
+			return nil, err
+		}
+		result[idx] = m
+	}
+	return result, translate(rows.Err())
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// queryer — общее у *sql.DB и *sql.Tx для чтения.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// detailsBatch ограничивает число id в одном запросе связанных данных.
+const detailsBatch = 500
+
+// attachDetails загружает приостановки, промо-расписания, смены цены и участников подписок; одна
+// подписка может встречаться в subs несколько раз.
+func (s *Store) attachDetails(ctx context.Context, q queryer, subs []*Subscription) error {
+	byID := make(map[uuid.UUID][]*Subscription, len(subs))
+	ids := make([]any, 0, len(subs))
+	for _, sub := range subs {
+		if _, ok := byID[sub.ID]; !ok {
+			ids = append(ids, sub.ID)
+		}
+		byID[sub.ID] = append(byID[sub.ID], sub)
+	}
+
+	for len(ids) > 0 {
+		batch := ids[:min(detailsBatch, len(ids))]
+		ids = ids[len(batch):]
+		placeholders := make([]string, 0, len(batch))
+		for i := range batch {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		}
+		in := strings.Join(placeholders, ", ")
+
+		err := s.loadDetails(ctx, q, `SELECT subscription_id, start_month, end_month FROM subscription_pauses
+WHERE subscription_id IN (`+in+`) ORDER BY start_month`, batch, func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var pause Pause
+			var until sql.NullTime
+			if err := rows.Scan(&id, &pause.From, &until); err != nil {
+				return err
+			}
+			if until.Valid {
+				pause.Until = &until.Time
+			}
+			for _, sub := range byID[id] {
+				sub.Pauses = append(sub.Pauses, pause)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.loadDetails(ctx, q, `SELECT subscription_id, months, price FROM subscription_promotions
+WHERE subscription_id IN (`+in+`) ORDER BY position`, batch, func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var promo Promotion
+			if err := rows.Scan(&id, &promo.Months, &promo.Price); err != nil {
+				return err
+			}
+			for _, sub := range byID[id] {
+				sub.Promotions = append(sub.Promotions, promo)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.loadDetails(ctx, q, `SELECT subscription_id, effective_month, price FROM subscription_price_changes
+WHERE subscription_id IN (`+in+`) ORDER BY effective_month`, batch, func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var change PriceChange
+			if err := rows.Scan(&id, &change.From, &change.Price); err != nil {
+				return err
+			}
+			for _, sub := range byID[id] {
+				sub.PriceChanges = append(sub.PriceChanges, change)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.loadDetails(ctx, q, `SELECT subscription_id, user_id, share FROM subscription_members
+WHERE subscription_id IN (`+in+`) ORDER BY share DESC, user_id`, batch, func(rows *sql.Rows) error {
+			var id uuid.UUID
+			var member Member
+			if err := rows.Scan(&id, &member.UserID, &member.Share); err != nil {
+				return err
+			}
+			for _, sub := range byID[id] {
+				sub.Members = append(sub.Members, member)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDetails выполняет запрос и передаёт каждую строку в scan.
+func (s *Store) loadDetails(ctx context.Context, q queryer, query string, args []any, scan func(rows *sql.Rows) error) error {
+	rows, err := q.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return translate(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return translate(rows.Err())
+}
+
+// saveDetails заменяет промо-расписание, смены цены и участников подписки
+// внутри транзакции.
+func (s *Store) saveDetails(ctx context.Context, tx *sql.Tx, sub *Subscription) error {
+	if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM subscription_promotions WHERE subscription_id = $1`), sub.ID); err != nil {
+		return err
+	}
+	for i, promo := range sub.Promotions {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`INSERT INTO subscription_promotions (subscription_id, position, months, price) VALUES ($1, $2, $3, $4)`),
+			sub.ID, i, promo.Months, promo.Price); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM subscription_price_changes WHERE subscription_id = $1`), sub.ID); err != nil {
+		return err
+	}
+	for _, change := range sub.PriceChanges {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`INSERT INTO subscription_price_changes (subscription_id, effective_month, price) VALUES ($1, $2, $3)`),
+			sub.ID, monthOf(change.From), change.Price); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM subscription_members WHERE subscription_id = $1`), sub.ID); err != nil {
+		return err
+	}
+	for _, member := range sub.Members {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`INSERT INTO subscription_members (subscription_id, user_id, share) VALUES ($1, $2, $3)`),
+			sub.ID, member.UserID, member.Share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
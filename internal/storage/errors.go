@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Виды ошибок хранилища. Store и MemoryStore возвращают ошибки, которые
+// сопоставляются с ними через errors.Is, независимо от драйвера базы.
+var (
+	// ErrNotFound — записи нет или она удалена.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate — запись с таким ключом уже существует.
+	ErrDuplicate = errors.New("duplicate")
+	// ErrConstraint — данные нарушают ограничение схемы (CHECK, NOT NULL, внешний ключ).
+	ErrConstraint = errors.New("constraint violation")
+	// ErrConflict — операции помешало параллельное изменение; её можно повторить.
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidState — операция недопустима в текущем состоянии подписки
+	// (например, возобновление неприостановленной подписки).
+	ErrInvalidState = errors.New("invalid state")
+	// ErrOverlap — подписка пересекается с другими подписками пользователя на
+	// тот же сервис, а хранилище настроено их отклонять; см. OverlapError.
+	ErrOverlap = errors.New("overlapping subscription")
+)
+
+// Error связывает ошибку драйвера с видом ошибки хранилища.
+type Error struct {
+	// Kind — одна из ErrNotFound, ErrDuplicate, ErrConstraint, ErrConflict,
+	// ErrInvalidState, ErrOverlap.
+	Kind error
+	// Constraint — имя нарушенного ограничения, если база его сообщила.
+	Constraint string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return fmt.Sprintf("%v %q: %v", e.Kind, e.Constraint, e.Err)
+	}
+	return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+}
+
+// Unwrap позволяет проверять и вид ошибки, и исходную ошибку драйвера.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// OverlapError перечисляет подписки, с которыми пересекается записываемая.
+type OverlapError struct {
+	Subscriptions []Subscription
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("subscription overlaps %d existing subscription(s) of the same user", len(e.Subscriptions))
+}
+
+// Translate переводит ошибки драйверов в ошибки хранилища так же, как это
+// делает Store; его используют хранилища других пакетов поверх той же базы.
+func Translate(err error) error {
+	return translate(err)
+}
+
+// translate переводит ошибки драйверов в ошибки хранилища; остальные
+// ошибки возвращаются без изменений.
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var storeErr *Error
+	if errors.As(err, &storeErr) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if kind := pqErrorKind(pqErr); kind != nil {
+			return &Error{Kind: kind, Constraint: pqErr.Constraint, Err: err}
+		}
+		return err
+	}
+	// Имён ограничений SQLite в коде ошибки не сообщает.
+	if kind := sqliteErrorKind(err); kind != nil {
+		return &Error{Kind: kind, Err: err}
+	}
+	return err
+}
+
+// pqErrorKind сопоставляет коды SQLSTATE Postgres с видами ошибок.
+func pqErrorKind(err *pq.Error) error {
+	switch err.Code.Name() {
+	case "unique_violation":
+		return ErrDuplicate
+	case "check_violation", "not_null_violation", "foreign_key_violation", "exclusion_violation",
+		"string_data_right_truncation", "numeric_value_out_of_range":
+		return ErrConstraint
+	case "serialization_failure", "deadlock_detected", "lock_not_available":
+		return ErrConflict
+	default:
+		return nil
+	}
+}
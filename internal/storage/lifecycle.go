@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Статусы подписки, вычисляемые на заданный месяц.
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusScheduled = "scheduled"
+	StatusEnded     = "ended"
+)
+
+// Pause — приостановка подписки на месяцы с From по Until включительно.
+// Until пуст, пока подписка приостановлена бессрочно, до Resume.
+type Pause struct {
+	From  time.Time  `json:"from"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// covers сообщает, приходится ли месяц month на приостановку.
+func (p Pause) covers(month time.Time) bool {
+	return !month.Before(p.From) && (p.Until == nil || !month.After(*p.Until))
+}
+
+// Status возвращает статус подписки в месяце, которому принадлежит now:
+// ещё не началась, уже закончилась, приостановлена или действует.
+func (s *Subscription) Status(now time.Time) string {
+	month := monthOf(now)
+	switch {
+	case s.StartDate.After(month):
+		return StatusScheduled
+	case s.EndDate != nil && s.EndDate.Before(month):
+		return StatusEnded
+	case s.PausedIn(month):
+		return StatusPaused
+	default:
+		return StatusActive
+	}
+}
+
+// PausedIn сообщает, приостановлена ли подписка в месяце month.
+func (s *Subscription) PausedIn(month time.Time) bool {
+	month = monthOf(month)
+	for _, p := range s.Pauses {
+		if p.covers(month) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCancel проверяет отмену подписки с месяца month: month становится
+// первым неоплачиваемым месяцем, и возвращается новая дата окончания.
+func checkCancel(sub *Subscription, month time.Time) (time.Time, error) {
+	month = monthOf(month)
+	if !month.After(sub.StartDate) {
+		return time.Time{}, invalidState("cancellation month %s must be after the start month %s",
+			formatMonth(month), formatMonth(sub.StartDate))
+	}
+	end := month.AddDate(0, -1, 0)
+	if sub.EndDate != nil && sub.EndDate.Before(end) {
+		return time.Time{}, invalidState("subscription already ends in %s", formatMonth(*sub.EndDate))
+	}
+	return end, nil
+}
+
+// checkPause проверяет новую приостановку: она должна начинаться в срок
+// подписки и не пересекаться с уже существующими.
+func checkPause(sub *Subscription, pause Pause) error {
+	if pause.From.Before(sub.StartDate) || sub.EndDate != nil && pause.From.After(*sub.EndDate) {
+		return invalidState("pause must start within the subscription period")
+	}
+	if pause.Until != nil && pause.Until.Before(pause.From) {
+		return invalidState("pause must not end before it starts")
+	}
+	for _, existing := range sub.Pauses {
+		if (existing.Until == nil || !pause.From.After(*existing.Until)) &&
+			(pause.Until == nil || !existing.From.After(*pause.Until)) {
+			return invalidState("subscription is already paused from %s", formatMonth(existing.From))
+		}
+	}
+	return nil
+}
+
+// checkInitialPauses нормализует приостановки, переданные при создании
+// подписки, и проверяет их по одной так же, как Pause.
+func checkInitialPauses(sub *Subscription) error {
+	pauses := sub.Pauses
+	sub.Pauses = nil
+	for _, pause := range pauses {
+		pause = normalizePause(pause)
+		if err := checkPause(sub, pause); err != nil {
+			sub.Pauses = pauses
+			return err
+		}
+		sub.Pauses = append(sub.Pauses, pause)
+		sortPauses(sub.Pauses)
+	}
+	return nil
+}
+
+// planResume находит приостановку, которую завершает возобновление с месяца
+// month: текущую или ближайшую будущую. Если она начинается не раньше month,
+// её нужно удалить (remove), иначе — закончить предыдущим месяцем (until).
+func planResume(sub *Subscription, month time.Time) (pause Pause, until time.Time, remove bool, err error) {
+	month = monthOf(month)
+	for _, p := range sub.Pauses {
+		if p.Until != nil && p.Until.Before(month) {
+			continue
+		}
+		if !p.From.Before(month) {
+			return p, time.Time{}, true, nil
+		}
+		return p, month.AddDate(0, -1, 0), false, nil
+	}
+	return Pause{}, time.Time{}, false, invalidState("subscription is not paused in or after %s", formatMonth(month))
+}
+
+// sortPauses упорядочивает приостановки по началу.
+func sortPauses(pauses []Pause) {
+	sort.Slice(pauses, func(i, j int) bool {
+		return pauses[i].From.Before(pauses[j].From)
+	})
+}
+
+func invalidState(format string, args ...any) error {
+	return &Error{Kind: ErrInvalidState, Err: fmt.Errorf(format, args...)}
+}
+
+// monthOf возвращает первый день месяца t в UTC.
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func formatMonth(t time.Time) string {
+	return t.Format("01-2006")
+}
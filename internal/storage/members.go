@@ -0,0 +1,45 @@
+package storage
+
+import "github.com/google/uuid"
+
+// Member — участник совместной подписки и его доля стоимости в процентах.
+type Member struct {
+	UserID uuid.UUID `json:"user_id"`
+	Share  int       `json:"share"`
+}
+
+// ShareOf возвращает долю пользователя user в стоимости подписки в процентах.
+// Владелец (UserID) платит то, что не распределено между участниками.
+func (s *Subscription) ShareOf(user uuid.UUID) int {
+	share := 0
+	for _, m := range s.Members {
+		if m.UserID == user {
+			return m.Share
+		}
+		share += m.Share
+	}
+	if user == s.UserID {
+		return 100 - share
+	}
+	return 0
+}
+
+// sharedWith сообщает, относится ли подписка к пользователю user — как к
+// владельцу или как к участнику.
+func (s *Subscription) sharedWith(user uuid.UUID) bool {
+	if s.UserID == user {
+		return true
+	}
+	for _, m := range s.Members {
+		if m.UserID == user {
+			return true
+		}
+	}
+	return false
+}
+
+// costFor возвращает часть стоимости cost, приходящуюся на user, с
+// округлением до рубля.
+func (s *Subscription) costFor(user uuid.UUID, cost int) int {
+	return (cost*s.ShareOf(user) + 50) / 100
+}
@@ -0,0 +1,640 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/google/uuid"
+)
+
+// MemoryStore — реализация SubscriptionRepository в памяти процесса для
+// локальной разработки и тестов. Повторяет семантику Store: мягкое удаление,
+// сортировку, фильтры с ILIKE и правила пересечения периодов в Summary.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	subs   map[uuid.UUID]*memoryRecord
+	events []events.Event
+	// rejectOverlaps — отклонять пересекающиеся подписки, см. RejectOverlaps.
+	rejectOverlaps bool
+}
+
+type memoryRecord struct {
+	sub       Subscription
+	deletedAt *time.Time
+}
+
+// NewMemoryStore создаёт пустое хранилище в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subs: make(map[uuid.UUID]*memoryRecord)}
+}
+
+// Create сохраняет запись подписки вместе с её приостановками и заполняет id
+// и created_at.
+func (m *MemoryStore) Create(_ context.Context, sub *Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sub.ID == uuid.Nil {
+		sub.ID = uuid.New()
+	}
+	if _, ok := m.subs[sub.ID]; ok {
+		return &Error{Kind: ErrDuplicate, Constraint: "subscriptions_pkey", Err: fmt.Errorf("subscription %s already exists", sub.ID)}
+	}
+	setDefaults(sub)
+	if err := checkConstraints(sub); err != nil {
+		return err
+	}
+	if err := checkInitialPauses(sub); err != nil {
+		return err
+	}
+	if err := m.checkOverlaps(sub); err != nil {
+		return err
+	}
+
+	sub.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	stored := normalize(sub)
+	m.subs[sub.ID] = &memoryRecord{sub: stored}
+	return m.recordEvent(events.SubscriptionCreated, &stored)
+}
+
+// Get загружает подписку по id.
+func (m *MemoryStore) Get(_ context.Context, id uuid.UUID) (*Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.subs[id]
+	if !ok || rec.deletedAt != nil {
+		return nil, ErrNotFound
+	}
+	sub := clone(rec.sub)
+	return &sub, nil
+}
+
+// List возвращает подписки, подходящие под фильтры, новые первыми.
+func (m *MemoryStore) List(_ context.Context, filter ListFilter) ([]Subscription, error) {
+	var service *regexp.Regexp
+	if filter.ServiceName != nil {
+		service = likePattern(*filter.ServiceName)
+	}
+
+	m.mu.RLock()
+	result := make([]Subscription, 0)
+	for _, rec := range m.subs {
+		sub := &rec.sub
+		if rec.deletedAt != nil {
+			continue
+		}
+		if filter.UserID != nil && sub.UserID != *filter.UserID {
+			continue
+		}
+		if service != nil && !service.MatchString(sub.ServiceName) {
+			continue
+		}
+		if filter.ActiveIn != nil && !overlaps(sub, *filter.ActiveIn, *filter.ActiveIn) {
+			continue
+		}
+		if filter.EndsIn != nil && (sub.EndDate == nil || !sub.EndDate.Equal(*filter.EndsIn)) {
+			continue
+		}
+		result = append(result, clone(*sub))
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	if filter.Offset > 0 {
+		if filter.Offset >= len(result) {
+			return result[:0], nil
+		}
+		result = result[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// Update обновляет существующую запись подписки и заполняет created_at.
+func (m *MemoryStore) Update(_ context.Context, sub *Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.subs[sub.ID]
+	if !ok || rec.deletedAt != nil {
+		return ErrNotFound
+	}
+	setDefaults(sub)
+	if err := checkConstraints(sub); err != nil {
+		return err
+	}
+	if err := m.checkOverlaps(sub); err != nil {
+		return err
+	}
+
+	sub.CreatedAt = rec.sub.CreatedAt
+	sub.Pauses = clone(rec.sub).Pauses
+	rec.sub = normalize(sub)
+	return m.recordEvent(events.SubscriptionUpdated, &rec.sub)
+}
+
+// Delete помечает запись подписки удалённой.
+func (m *MemoryStore) Delete(_ context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.subs[id]
+	if !ok || rec.deletedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	rec.deletedAt = &now
+	return m.recordEvent(events.SubscriptionDeleted, DeletedSubscription{ID: id})
+}
+
+// Purge физически удаляет подписки, помеченные удалёнными раньше before.
+func (m *MemoryStore) Purge(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, rec := range m.subs {
+		if rec.deletedAt != nil && rec.deletedAt.Before(before) {
+			delete(m.subs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Summary считает суммарную цену подписок в периоде по правилам Store.Summary.
+func (m *MemoryStore) Summary(_ context.Context, filter SummaryFilter) (int, error) {
+	subs := m.summaryCandidates(filter)
+	total := 0
+	for i := range subs {
+		total += summaryCost(&subs[i], filter)
+	}
+	return total, nil
+}
+
+// Forecast прогнозирует расходы по месяцам по правилам Store.Forecast.
+func (m *MemoryStore) Forecast(_ context.Context, filter SummaryFilter) ([]MonthlyCost, error) {
+	return forecast(m.summaryCandidates(filter), filter), nil
+}
+
+// Activity считает подписки по месяцам по правилам Store.Activity.
+func (m *MemoryStore) Activity(_ context.Context, filter SummaryFilter) ([]Activity, error) {
+	subs := m.summaryCandidates(filter)
+	result := make([]Activity, 0)
+	for month := monthOf(filter.PeriodStart); !month.After(filter.PeriodEnd); month = month.AddDate(0, 1, 0) {
+		a := Activity{Month: month}
+		end := month.AddDate(0, 1, -1)
+		for i := range subs {
+			sub := &subs[i]
+			if overlaps(sub, month, end) && !sub.PausedIn(month) {
+				a.Active++
+			}
+			if monthOf(sub.StartDate).Equal(month) {
+				a.New++
+			}
+			if sub.EndDate != nil && monthOf(*sub.EndDate).Equal(month) {
+				a.Ended++
+			}
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// ServicePrices считает цены по сервисам по правилам Store.ServicePrices.
+func (m *MemoryStore) ServicePrices(_ context.Context, filter SummaryFilter) ([]ServicePrice, error) {
+	names := make(map[string]string)
+	prices := make(map[string][]int)
+	for _, sub := range m.summaryCandidates(filter) {
+		key := strings.ToLower(sub.ServiceName)
+		if name, ok := names[key]; !ok || sub.ServiceName < name {
+			names[key] = sub.ServiceName
+		}
+		prices[key] = append(prices[key], sub.Price)
+	}
+
+	keys := make([]string, 0, len(prices))
+	for key := range prices {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]ServicePrice, 0, len(keys))
+	for _, key := range keys {
+		list := prices[key]
+		sort.Ints(list)
+		sum := 0
+		for _, price := range list {
+			sum += price
+		}
+		n := len(list)
+		result = append(result, ServicePrice{
+			ServiceName:   names[key],
+			Subscriptions: n,
+			AveragePrice:  float64(sum) / float64(n),
+			MedianPrice:   float64(list[(n-1)/2]+list[n/2]) / 2,
+		})
+	}
+	return result, nil
+}
+
+// Lifetime считает продолжительность подписок по правилам Store.Lifetime.
+func (m *MemoryStore) Lifetime(_ context.Context, filter SummaryFilter) (*Lifetime, error) {
+	var l Lifetime
+	total, totalEnded := 0, 0
+	for _, sub := range m.summaryCandidates(filter) {
+		last := monthOf(filter.PeriodEnd)
+		ended := sub.EndDate != nil && !sub.EndDate.After(filter.PeriodEnd)
+		if sub.EndDate != nil && sub.EndDate.Before(filter.PeriodEnd) {
+			last = monthOf(*sub.EndDate)
+		}
+		months := monthsBetween(monthOf(sub.StartDate), last) + 1
+		l.Subscriptions++
+		total += months
+		if ended {
+			l.Ended++
+			totalEnded += months
+		}
+	}
+	if l.Subscriptions > 0 {
+		l.AverageMonths = float64(total) / float64(l.Subscriptions)
+	}
+	if l.Ended > 0 {
+		l.AverageEndedMonths = float64(totalEnded) / float64(l.Ended)
+	}
+	return &l, nil
+}
+
+// summaryCandidates возвращает копии подписок, попадающих в период и фильтры Summary.
+func (m *MemoryStore) summaryCandidates(filter SummaryFilter) []Subscription {
+	var service *regexp.Regexp
+	if filter.ServiceName != nil {
+		service = likePattern(*filter.ServiceName)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Subscription, 0)
+	for _, rec := range m.subs {
+		sub := &rec.sub
+		if rec.deletedAt != nil || !overlaps(sub, filter.PeriodStart, filter.PeriodEnd) {
+			continue
+		}
+		if filter.UserID != nil && !sub.sharedWith(*filter.UserID) {
+			continue
+		}
+		if service != nil && !service.MatchString(sub.ServiceName) {
+			continue
+		}
+		if filter.Category != nil && (sub.Category == nil || !strings.EqualFold(*sub.Category, *filter.Category)) {
+			continue
+		}
+		result = append(result, clone(*sub))
+	}
+	return result
+}
+
+// Overlapping возвращает действующие подписки того же пользователя на тот же
+// сервис, период которых пересекается с периодом sub; сама sub не учитывается.
+func (m *MemoryStore) Overlapping(_ context.Context, sub *Subscription) ([]Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.overlapping(sub), nil
+}
+
+// RejectOverlaps включает отказ в записи пересекающихся подписок, как у Store.
+func (m *MemoryStore) RejectOverlaps(reject bool) {
+	m.rejectOverlaps = reject
+}
+
+// checkOverlaps отклоняет sub, если она пересекается с другими подписками
+// пользователя. Вызывается под m.mu.
+func (m *MemoryStore) checkOverlaps(sub *Subscription) error {
+	if !m.rejectOverlaps {
+		return nil
+	}
+	if overlapping := m.overlapping(sub); len(overlapping) > 0 {
+		return &Error{Kind: ErrOverlap, Err: &OverlapError{Subscriptions: overlapping}}
+	}
+	return nil
+}
+
+// overlapping — Overlapping без блокировки; вызывается под m.mu.
+func (m *MemoryStore) overlapping(sub *Subscription) []Subscription {
+	result := make([]Subscription, 0)
+	for _, rec := range m.subs {
+		if rec.deletedAt != nil || rec.sub.ID == sub.ID || !sameService(&rec.sub, sub) {
+			continue
+		}
+		if rec.sub.EndDate != nil && rec.sub.EndDate.Before(dateOnly(sub.StartDate)) {
+			continue
+		}
+		if sub.EndDate != nil && rec.sub.StartDate.After(dateOnly(*sub.EndDate)) {
+			continue
+		}
+		result = append(result, clone(rec.sub))
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartDate.Before(result[j].StartDate)
+	})
+	return result
+}
+
+// Overlaps находит все пары пересекающихся подписок, подходящие под фильтр.
+func (m *MemoryStore) Overlaps(_ context.Context, filter OverlapFilter) ([]Overlap, error) {
+	var service *regexp.Regexp
+	if filter.ServiceName != nil {
+		service = likePattern(*filter.ServiceName)
+	}
+
+	m.mu.RLock()
+	active := make([]*Subscription, 0, len(m.subs))
+	for _, rec := range m.subs {
+		if rec.deletedAt == nil {
+			active = append(active, &rec.sub)
+		}
+	}
+	result := make([]Overlap, 0)
+	for _, a := range active {
+		if filter.UserID != nil && a.UserID != *filter.UserID {
+			continue
+		}
+		if service != nil && !service.MatchString(a.ServiceName) {
+			continue
+		}
+		for _, b := range active {
+			if a.ID.String() >= b.ID.String() || !sameService(a, b) {
+				continue
+			}
+			if b.EndDate != nil && a.StartDate.After(*b.EndDate) || a.EndDate != nil && b.StartDate.After(*a.EndDate) {
+				continue
+			}
+			result = append(result, newOverlap(clone(*a), clone(*b)))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.First.UserID != b.First.UserID {
+			return a.First.UserID.String() < b.First.UserID.String()
+		}
+		if !a.First.StartDate.Equal(b.First.StartDate) {
+			return a.First.StartDate.Before(b.First.StartDate)
+		}
+		return a.Second.StartDate.Before(b.Second.StartDate)
+	})
+
+	if filter.Offset > 0 {
+		if filter.Offset >= len(result) {
+			return result[:0], nil
+		}
+		result = result[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// Cancel отменяет подписку с месяца month.
+func (m *MemoryStore) Cancel(_ context.Context, id uuid.UUID, month time.Time) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.subs[id]
+	if !ok || rec.deletedAt != nil {
+		return nil, ErrNotFound
+	}
+	end, err := checkCancel(&rec.sub, month)
+	if err != nil {
+		return nil, err
+	}
+	rec.sub.EndDate = &end
+	return m.changed(events.SubscriptionCancelled, rec)
+}
+
+// Pause приостанавливает подписку на месяцы pause.From..pause.Until.
+func (m *MemoryStore) Pause(_ context.Context, id uuid.UUID, pause Pause) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.subs[id]
+	if !ok || rec.deletedAt != nil {
+		return nil, ErrNotFound
+	}
+	pause = normalizePause(pause)
+	if err := checkPause(&rec.sub, pause); err != nil {
+		return nil, err
+	}
+	rec.sub.Pauses = append(rec.sub.Pauses, pause)
+	sortPauses(rec.sub.Pauses)
+	return m.changed(events.SubscriptionPaused, rec)
+}
+
+// Resume возобновляет подписку с месяца month.
+func (m *MemoryStore) Resume(_ context.Context, id uuid.UUID, month time.Time) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rec, ok := m.subs[id]
+	if !ok || rec.deletedAt != nil {
+		return nil, ErrNotFound
+	}
+	pause, until, remove, err := planResume(&rec.sub, month)
+	if err != nil {
+		return nil, err
+	}
+	rec.sub.Pauses = resumed(rec.sub.Pauses, pause, until, remove)
+	return m.changed(events.SubscriptionResumed, rec)
+}
+
+// changed записывает событие об изменении rec и возвращает копию подписки;
+// вызывается под m.mu.
+func (m *MemoryStore) changed(eventType string, rec *memoryRecord) (*Subscription, error) {
+	if err := m.recordEvent(eventType, &rec.sub); err != nil {
+		return nil, err
+	}
+	sub := clone(rec.sub)
+	return &sub, nil
+}
+
+// sameService сообщает, что подписки принадлежат одному пользователю и сервису.
+func sameService(a, b *Subscription) bool {
+	return a.UserID == b.UserID && strings.EqualFold(a.ServiceName, b.ServiceName)
+}
+
+// memoryEventLimit — сколько последних событий хранит MemoryStore.
+const memoryEventLimit = 1000
+
+// Events возвращает последние memoryEventLimit событий, записанных при
+// изменениях (аналог таблицы outbox). Ретранслятора в этом режиме нет,
+// поэтому более старые события отбрасываются, чтобы не копить их без предела.
+func (m *MemoryStore) Events() []events.Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]events.Event(nil), m.events...)
+}
+
+// recordEvent добавляет доменное событие; вызывается под m.mu.
+func (m *MemoryStore) recordEvent(eventType string, data any) error {
+	event, err := events.New(eventType, data)
+	if err != nil {
+		return err
+	}
+	if len(m.events) == memoryEventLimit {
+		// Сдвигаем на месте, чтобы не держать отброшенные события в массиве.
+		copy(m.events, m.events[1:])
+		m.events = m.events[:len(m.events)-1]
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+// checkConstraints повторяет CHECK-ограничения таблиц subscriptions,
+// subscription_promotions и subscription_members.
+func checkConstraints(sub *Subscription) error {
+	if sub.Price < 0 {
+		return &Error{Kind: ErrConstraint, Constraint: "subscriptions_price_check", Err: errors.New("price must be non-negative")}
+	}
+	if sub.TrialMonths < 0 {
+		return &Error{Kind: ErrConstraint, Constraint: "subscriptions_trial_months_check", Err: errors.New("trial_months must be non-negative")}
+	}
+	if sub.BillingMonths < 1 || sub.BillingMonths > 12 {
+		return &Error{Kind: ErrConstraint, Constraint: "subscriptions_billing_months_check", Err: errors.New("billing_months must be between 1 and 12")}
+	}
+	for _, change := range sub.PriceChanges {
+		if change.Price < 0 {
+			return &Error{Kind: ErrConstraint, Constraint: "subscription_price_changes_price_check", Err: errors.New("price change must be non-negative")}
+		}
+	}
+	for _, member := range sub.Members {
+		if member.Share < 1 || member.Share > 100 {
+			return &Error{Kind: ErrConstraint, Constraint: "subscription_members_share_check", Err: errors.New("member share must be between 1 and 100")}
+		}
+	}
+	for _, promo := range sub.Promotions {
+		if promo.Months <= 0 {
+			return &Error{Kind: ErrConstraint, Constraint: "subscription_promotions_months_check", Err: errors.New("promotion months must be positive")}
+		}
+		if promo.Price < 0 {
+			return &Error{Kind: ErrConstraint, Constraint: "subscription_promotions_price_check", Err: errors.New("promotion price must be non-negative")}
+		}
+	}
+	return nil
+}
+
+// overlaps повторяет условие SQL: start_date <= to AND (end_date IS NULL OR end_date >= from).
+func overlaps(sub *Subscription, from, to time.Time) bool {
+	if sub.StartDate.After(to) {
+		return false
+	}
+	return sub.EndDate == nil || !sub.EndDate.Before(from)
+}
+
+// normalize копирует подписку и обрезает даты до дня, как колонка DATE в Postgres.
+func normalize(sub *Subscription) Subscription {
+	result := clone(*sub)
+	result.StartDate = dateOnly(result.StartDate)
+	if result.EndDate != nil {
+		end := dateOnly(*result.EndDate)
+		result.EndDate = &end
+	}
+	return result
+}
+
+// clone возвращает копию подписки, не разделяющую указатели с исходной.
+func clone(sub Subscription) Subscription {
+	if sub.Category != nil {
+		category := *sub.Category
+		sub.Category = &category
+	}
+	if sub.EndDate != nil {
+		end := *sub.EndDate
+		sub.EndDate = &end
+	}
+	if sub.Promotions != nil {
+		sub.Promotions = append([]Promotion(nil), sub.Promotions...)
+	}
+	if sub.PriceChanges != nil {
+		sub.PriceChanges = append([]PriceChange(nil), sub.PriceChanges...)
+	}
+	if sub.Members != nil {
+		sub.Members = append([]Member(nil), sub.Members...)
+	}
+	if sub.Pauses != nil {
+		sub.Pauses = append([]Pause(nil), sub.Pauses...)
+	}
+	return sub
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// likePattern переводит шаблон ILIKE в регулярное выражение: % — любая
+// последовательность, _ — один символ, \ экранирует следующий символ.
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?is)^`)
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(`.*`)
+		case r == '_':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
+
+// forecast раскладывает расходы подписок subs по месяцам периода filter.
+func forecast(subs []Subscription, filter SummaryFilter) []MonthlyCost {
+	result := make([]MonthlyCost, 0)
+	for month := monthOf(filter.PeriodStart); !month.After(filter.PeriodEnd); month = month.AddDate(0, 1, 0) {
+		monthly := filter
+		monthly.PeriodStart, monthly.PeriodEnd = month, month.AddDate(0, 1, -1)
+		cost := MonthlyCost{Month: month}
+		for i := range subs {
+			if !overlaps(&subs[i], monthly.PeriodStart, monthly.PeriodEnd) {
+				continue
+			}
+			if subs[i].PausedIn(month) || !subs[i].DueIn(month) {
+				continue
+			}
+			cost.Total += summaryCost(&subs[i], monthly)
+			cost.Subscriptions++
+		}
+		result = append(result, cost)
+	}
+	return result
+}
+
+// summaryCost возвращает вклад подписки в Summary с фильтром filter.
+func summaryCost(sub *Subscription, filter SummaryFilter) int {
+	return sub.chargeIn(filter.PeriodStart, filter.PeriodEnd, filter.UserID)
+}
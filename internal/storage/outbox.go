@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/google/uuid"
+)
+
+// DeletedSubscription — данные события subscription.deleted.
+type DeletedSubscription struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// withTx выполняет fn в транзакции и фиксирует её, если fn не вернула ошибку.
+// Ошибки драйвера переводятся в ошибки хранилища.
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translate(err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return translate(err)
+	}
+	return translate(tx.Commit())
+}
+
+// insertEvent записывает доменное событие в таблицу outbox внутри транзакции
+// изменения, чтобы событие и данные фиксировались или откатывались вместе.
+func (s *Store) insertEvent(ctx context.Context, tx *sql.Tx, eventType string, data any) error {
+	event, err := events.New(eventType, data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.dialect.Rebind(
+		`INSERT INTO outbox (id, event_type, data, occurred_at) VALUES ($1, $2, $3, $4)`),
+		event.ID, event.Type, string(event.Data), event.OccurredAt,
+	)
+	return err
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/google/uuid"
+)
+
+// Overlap — пара действующих подписок одного пользователя на один и тот же
+// сервис (без учёта регистра), периоды которых пересекаются.
+type Overlap struct {
+	First  Subscription `json:"first"`
+	Second Subscription `json:"second"`
+	// From и To — общий период; To пуст, если обе подписки бессрочные.
+	From time.Time  `json:"from"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// OverlapFilter задаёт фильтры для поиска пересечений по всем подпискам.
+type OverlapFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string
+	Limit       int
+	Offset      int
+}
+
+// overlapLockClass — первый ключ advisory lock, которым Postgres сериализует
+// проверку пересечений по пользователю; второй ключ — хэш user_id.
+const overlapLockClass = 7202
+
+// RejectOverlaps включает отказ в записи пересекающихся подписок: Create и
+// Update возвращают ошибку вида ErrOverlap. Вызывается до начала работы.
+func (s *Store) RejectOverlaps(reject bool) {
+	s.rejectOverlaps = reject
+}
+
+// Overlapping возвращает действующие подписки того же пользователя на тот же
+// сервис, период которых пересекается с периодом sub; сама sub не учитывается.
+func (s *Store) Overlapping(ctx context.Context, sub *Subscription) ([]Subscription, error) {
+	query, args := s.overlappingQuery(sub)
+	return s.query(ctx, query, args...)
+}
+
+// checkOverlaps в транзакции записи tx отклоняет sub, если она пересекается
+// с другими подписками пользователя. В Postgres проверка и запись по одному
+// пользователю сериализуются advisory lock до конца транзакции; в SQLite
+// транзакции записи и так выполняются по одной.
+func (s *Store) checkOverlaps(ctx context.Context, tx *sql.Tx, sub *Subscription) error {
+	if !s.rejectOverlaps {
+		return nil
+	}
+	if s.dialect == database.Postgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`,
+			overlapLockClass, sub.UserID.String()); err != nil {
+			return err
+		}
+	}
+	query, args := s.overlappingQuery(sub)
+	overlapping, err := s.queryWith(ctx, tx, query, args...)
+	if err != nil {
+		return err
+	}
+	if len(overlapping) > 0 {
+		return &Error{Kind: ErrOverlap, Err: &OverlapError{Subscriptions: overlapping}}
+	}
+	return nil
+}
+
+// overlappingQuery строит запрос Overlapping для sub.
+func (s *Store) overlappingQuery(sub *Subscription) (string, []any) {
+	args := []any{sub.UserID, sub.ServiceName, sub.StartDate, sub.ID}
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions
+WHERE deleted_at IS NULL AND user_id = $1 AND ` + s.fold("service_name") + ` = ` + s.fold("CAST($2 AS TEXT)") + `
+AND (end_date IS NULL OR end_date >= $3) AND id <> $4`
+	if sub.EndDate != nil {
+		args = append(args, *sub.EndDate)
+		query += fmt.Sprintf(" AND start_date <= $%d", len(args))
+	}
+	query += " ORDER BY start_date"
+	return query, args
+}
+
+// Overlaps находит все пары пересекающихся подписок, подходящие под фильтр.
+func (s *Store) Overlaps(ctx context.Context, filter OverlapFilter) ([]Overlap, error) {
+	query := `SELECT ` + prefixColumns("a") + `, ` + prefixColumns("b") + `
+FROM subscriptions a JOIN subscriptions b
+ON a.user_id = b.user_id AND ` + s.fold("a.service_name") + ` = ` + s.fold("b.service_name") + ` AND a.id < b.id
+AND (b.end_date IS NULL OR a.start_date <= b.end_date) AND (a.end_date IS NULL OR b.start_date <= a.end_date)`
+	args := make([]any, 0, 4)
+	clauses := []string{"a.deleted_at IS NULL", "b.deleted_at IS NULL"}
+
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		clauses = append(clauses, fmt.Sprintf("a.user_id = $%d", len(args)))
+	}
+	if filter.ServiceName != nil {
+		args = append(args, *filter.ServiceName)
+		clauses = append(clauses, s.ilike("a.service_name", len(args)))
+	}
+	query += " WHERE " + strings.Join(clauses, " AND ")
+	query += " ORDER BY a.user_id, a.start_date, b.start_date"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, translate(err)
+	}
+	defer rows.Close()
+
+	result := make([]Overlap, 0)
+	for rows.Next() {
+		var first, second Subscription
+		var firstEnd, secondEnd sql.NullTime
+		dest := append(subscriptionDest(&first, &firstEnd), subscriptionDest(&second, &secondEnd)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		setEndDate(&first, firstEnd)
+		setEndDate(&second, secondEnd)
+		result = append(result, newOverlap(first, second))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translate(err)
+	}
+
+	subs := make([]*Subscription, 0, 2*len(result))
+	for i := range result {
+		subs = append(subs, &result[i].First, &result[i].Second)
+	}
+	if err := s.attachDetails(ctx, s.db, subs); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// prefixColumns возвращает subscriptionColumns с псевдонимом таблицы alias.
+func prefixColumns(alias string) string {
+	columns := strings.Split(subscriptionColumns, ", ")
+	for i, c := range columns {
+		columns[i] = alias + "." + c
+	}
+	return strings.Join(columns, ", ")
+}
+
+// newOverlap вычисляет общий период двух пересекающихся подписок.
+func newOverlap(first, second Subscription) Overlap {
+	overlap := Overlap{First: first, Second: second, From: first.StartDate}
+	if second.StartDate.After(overlap.From) {
+		overlap.From = second.StartDate
+	}
+	for _, end := range []*time.Time{first.EndDate, second.EndDate} {
+		if end != nil && (overlap.To == nil || end.Before(*overlap.To)) {
+			to := *end
+			overlap.To = &to
+		}
+	}
+	return overlap
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestRejectOverlaps проверяет, что хранилище с включённым RejectOverlaps
+// отклоняет пересечения при записи, в том числе параллельной, а обновление
+// несуществующей подписки отвечает ErrNotFound раньше проверки пересечений.
+func TestRejectOverlaps(t *testing.T) {
+	sqlStore, memStore := newSQLiteStore(t), NewMemoryStore()
+	sqlStore.RejectOverlaps(true)
+	memStore.RejectOverlaps(true)
+
+	for name, repo := range map[string]SubscriptionRepository{"sqlite": sqlStore, "memory": memStore} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			user := uuid.New()
+			newSub := func() *Subscription {
+				return &Subscription{ServiceName: "Video", Price: 100, UserID: user, StartDate: month(2025, time.January)}
+			}
+
+			const workers = 8
+			var wg sync.WaitGroup
+			errs := make([]error, workers)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = repo.Create(ctx, newSub())
+				}()
+			}
+			wg.Wait()
+
+			created := 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					created++
+				case !errors.Is(err, ErrOverlap):
+					t.Fatalf("Create: unexpected error %v", err)
+				}
+			}
+			if created != 1 {
+				t.Fatalf("created %d overlapping subscriptions, want 1", created)
+			}
+
+			missing := newSub()
+			missing.ID = uuid.New()
+			if err := repo.Update(ctx, missing); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Update of missing subscription: got %v, want ErrNotFound", err)
+			}
+
+			other := newSub()
+			other.ServiceName = "Music"
+			if err := repo.Create(ctx, other); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			other.ServiceName = "VIDEO"
+			err := repo.Update(ctx, other)
+			var overlapErr *OverlapError
+			if !errors.Is(err, ErrOverlap) || !errors.As(err, &overlapErr) || len(overlapErr.Subscriptions) != 1 {
+				t.Errorf("Update into overlap: got %v, want ErrOverlap with one subscription", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/google/uuid"
+)
+
+// Cancel отменяет подписку с месяца month: последним оплаченным становится
+// предыдущий месяц. В outbox пишется событие subscription.cancelled.
+func (s *Store) Cancel(ctx context.Context, id uuid.UUID, month time.Time) (*Subscription, error) {
+	var result *Subscription
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		sub, err := s.lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		end, err := checkCancel(sub, month)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`UPDATE subscriptions SET end_date = $1 WHERE id = $2`), end, id); err != nil {
+			return err
+		}
+		sub.EndDate = &end
+		result = sub
+		return s.insertEvent(ctx, tx, events.SubscriptionCancelled, sub)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Pause приостанавливает подписку на месяцы pause.From..pause.Until.
+// В outbox пишется событие subscription.paused.
+func (s *Store) Pause(ctx context.Context, id uuid.UUID, pause Pause) (*Subscription, error) {
+	pause = normalizePause(pause)
+	var result *Subscription
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		sub, err := s.lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkPause(sub, pause); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`INSERT INTO subscription_pauses (subscription_id, start_month, end_month, created_at) VALUES ($1, $2, $3, $4)`),
+			id, pause.From, pause.Until, time.Now().UTC()); err != nil {
+			return err
+		}
+		sub.Pauses = append(sub.Pauses, pause)
+		sortPauses(sub.Pauses)
+		result = sub
+		return s.insertEvent(ctx, tx, events.SubscriptionPaused, sub)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Resume возобновляет подписку с месяца month, завершая текущую или
+// ближайшую будущую приостановку. В outbox пишется событие subscription.resumed.
+func (s *Store) Resume(ctx context.Context, id uuid.UUID, month time.Time) (*Subscription, error) {
+	var result *Subscription
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		sub, err := s.lockSubscription(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		pause, until, remove, err := planResume(sub, month)
+		if err != nil {
+			return err
+		}
+		if remove {
+			_, err = tx.ExecContext(ctx, s.dialect.Rebind(
+				`DELETE FROM subscription_pauses WHERE subscription_id = $1 AND start_month = $2`), id, pause.From)
+		} else {
+			_, err = tx.ExecContext(ctx, s.dialect.Rebind(
+				`UPDATE subscription_pauses SET end_month = $1 WHERE subscription_id = $2 AND start_month = $3`), until, id, pause.From)
+		}
+		if err != nil {
+			return err
+		}
+		sub.Pauses = resumed(sub.Pauses, pause, until, remove)
+		result = sub
+		return s.insertEvent(ctx, tx, events.SubscriptionResumed, sub)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// lockSubscription загружает подписку со связанными данными внутри транзакции;
+// в Postgres строка блокируется до её завершения.
+func (s *Store) lockSubscription(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1 AND deleted_at IS NULL`
+	if s.dialect == database.Postgres {
+		query += " FOR UPDATE"
+	}
+	sub, err := scanSubscription(tx.QueryRowContext(ctx, s.dialect.Rebind(query), id))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachDetails(ctx, tx, []*Subscription{sub}); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// normalizePause приводит границы приостановки к первым числам месяцев.
+func normalizePause(pause Pause) Pause {
+	pause.From = monthOf(pause.From)
+	if pause.Until != nil {
+		until := monthOf(*pause.Until)
+		pause.Until = &until
+	}
+	return pause
+}
+
+// resumed возвращает приостановки после возобновления, найденного planResume.
+func resumed(pauses []Pause, target Pause, until time.Time, remove bool) []Pause {
+	result := make([]Pause, 0, len(pauses))
+	for _, p := range pauses {
+		if p.From.Equal(target.From) {
+			if remove {
+				continue
+			}
+			end := until
+			p.Until = &end
+		}
+		result = append(result, p)
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestCreateWithPauses проверяет, что приостановки, переданные в Create,
+// сохраняются вместе с подпиской, а неверная приостановка отклоняет всю
+// запись целиком.
+func TestCreateWithPauses(t *testing.T) {
+	for name, repo := range map[string]SubscriptionRepository{"sqlite": newSQLiteStore(t), "memory": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			sub := &Subscription{
+				ServiceName: "Video", Price: 100, UserID: uuid.New(), StartDate: month(2025, time.January),
+				Pauses: []Pause{
+					{From: month(2025, time.June)},
+					{From: month(2025, time.March), Until: ptr(month(2025, time.April))},
+				},
+			}
+			if err := repo.Create(ctx, sub); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			got, err := repo.Get(ctx, sub.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if len(got.Pauses) != 2 || !got.Pauses[0].From.Equal(month(2025, time.March)) || got.Pauses[1].Until != nil {
+				t.Errorf("Pauses = %+v, want March-April and open-ended from June", got.Pauses)
+			}
+
+			invalid := &Subscription{
+				ServiceName: "Music", Price: 100, UserID: uuid.New(), StartDate: month(2025, time.January),
+				Pauses: []Pause{{From: month(2025, time.March)}, {From: month(2025, time.May)}},
+			}
+			if err := repo.Create(ctx, invalid); !errors.Is(err, ErrInvalidState) {
+				t.Fatalf("Create with overlapping pauses: got %v, want ErrInvalidState", err)
+			}
+			if _, err := repo.Get(ctx, invalid.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after rejected Create: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Promotion — шаг промо-расписания: Months месяцев по цене Price.
+type Promotion struct {
+	Months int `json:"months"`
+	Price  int `json:"price"`
+}
+
+// PriceChange — запланированная смена полной цены с месяца From.
+type PriceChange struct {
+	From  time.Time `json:"from"`
+	Price int       `json:"price"`
+}
+
+// setDefaults подставляет значения по умолчанию для незаданных полей.
+func setDefaults(sub *Subscription) {
+	if sub.BillingMonths == 0 {
+		sub.BillingMonths = 1
+	}
+}
+
+// PriceIn возвращает цену подписки в месяце month. Первые TrialMonths
+// месяцев с начала бесплатны, затем по порядку действуют шаги Promotions,
+// после них — полная цена: Price или последняя наступившая PriceChanges.
+// Месяцы считаются календарными с start_date, приостановки расписание не
+// сдвигают.
+func (s *Subscription) PriceIn(month time.Time) int {
+	month = monthOf(month)
+	index := monthsBetween(s.StartDate, month)
+	if index < s.TrialMonths {
+		return 0
+	}
+	index -= s.TrialMonths
+	for _, p := range s.Promotions {
+		if index < p.Months {
+			return p.Price
+		}
+		index -= p.Months
+	}
+	return s.basePriceIn(month)
+}
+
+// basePriceIn возвращает полную цену в месяце month с учётом PriceChanges.
+func (s *Subscription) basePriceIn(month time.Time) int {
+	price := s.Price
+	for _, c := range s.PriceChanges {
+		if c.From.After(month) {
+			break
+		}
+		price = c.Price
+	}
+	return price
+}
+
+// DueIn сообщает, выпадает ли на месяц month списание: подписка с
+// BillingMonths > 1 оплачивается раз в BillingMonths месяцев начиная со
+// start_date.
+func (s *Subscription) DueIn(month time.Time) bool {
+	interval := max(s.BillingMonths, 1)
+	return monthsBetween(s.StartDate, monthOf(month))%interval == 0
+}
+
+// TrialEnd возвращает последний бесплатный месяц или nil, если пробного
+// периода нет.
+func (s *Subscription) TrialEnd() *time.Time {
+	if s.TrialMonths <= 0 {
+		return nil
+	}
+	end := monthOf(s.StartDate).AddDate(0, s.TrialMonths-1, 0)
+	return &end
+}
+
+// chargeIn возвращает начисление по подписке за период [from, to]: сумму
+// PriceIn по месяцам периода внутри её срока, которые не приостановлены и на
+// которые выпадает списание по BillingMonths. Если задан user, в каждом
+// месяце берётся только его доля (см. costFor), с округлением помесячно.
+func (s *Subscription) chargeIn(from, to time.Time, user *uuid.UUID) int {
+	month := monthOf(from)
+	if s.StartDate.After(month) {
+		month = monthOf(s.StartDate)
+	}
+	last := monthOf(to)
+	if s.EndDate != nil && s.EndDate.Before(last) {
+		last = monthOf(*s.EndDate)
+	}
+	total := 0
+	for ; !month.After(last); month = month.AddDate(0, 1, 0) {
+		if s.PausedIn(month) || !s.DueIn(month) {
+			continue
+		}
+		price := s.PriceIn(month)
+		if user != nil {
+			price = s.costFor(*user, price)
+		}
+		total += price
+	}
+	return total
+}
+
+// monthsBetween возвращает число месяцев от from до to.
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
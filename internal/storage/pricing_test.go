@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestChargeIn проверяет помесячное начисление при сочетаниях пробного
+// периода, промо-шагов, смен цены, приостановок и интервала оплаты.
+func TestChargeIn(t *testing.T) {
+	jan := month(2025, time.January)
+	tests := []struct {
+		name     string
+		sub      Subscription
+		from, to time.Time
+		want     int
+	}{
+		{
+			name: "trial then promotions then full price",
+			sub: Subscription{Price: 300, StartDate: jan, TrialMonths: 2,
+				Promotions: []Promotion{{Months: 2, Price: 100}, {Months: 1, Price: 150}}},
+			from: jan, to: month(2025, time.December),
+			// 0 + 0 + 100 + 100 + 150 + 7 * 300
+			want: 2450,
+		},
+		{
+			name: "period inside promotion",
+			sub: Subscription{Price: 300, StartDate: jan, TrialMonths: 1,
+				Promotions: []Promotion{{Months: 3, Price: 100}}},
+			from: month(2025, time.February), to: month(2025, time.March),
+			want: 200,
+		},
+		{
+			name: "pause does not shift trial",
+			sub: Subscription{Price: 200, StartDate: jan, TrialMonths: 2,
+				Pauses: []Pause{{From: month(2025, time.February), Until: ptr(month(2025, time.March))}}},
+			from: jan, to: month(2025, time.June),
+			// январь пробный, февраль и март приостановлены
+			want: 600,
+		},
+		{
+			name: "open pause stops charges",
+			sub: Subscription{Price: 100, StartDate: jan,
+				Pauses: []Pause{{From: month(2025, time.April)}}},
+			from: jan, to: month(2025, time.December),
+			want: 300,
+		},
+		{
+			name: "promotion with quarterly billing",
+			sub: Subscription{Price: 900, StartDate: jan, BillingMonths: 3,
+				Promotions: []Promotion{{Months: 4, Price: 300}}},
+			from: jan, to: month(2025, time.December),
+			// списания в январе и апреле по промо-цене, в июле и октябре — полные
+			want: 2400,
+		},
+		{
+			name: "trial covers billing months",
+			sub:  Subscription{Price: 500, StartDate: jan, BillingMonths: 2, TrialMonths: 3},
+			from: jan, to: month(2025, time.August),
+			// списания в январе и марте бесплатны, в мае и июле — полные
+			want: 1000,
+		},
+		{
+			name: "yearly billing month paused",
+			sub: Subscription{Price: 1200, StartDate: month(2024, time.March), BillingMonths: 12,
+				Pauses: []Pause{{From: month(2025, time.March), Until: ptr(month(2025, time.March))}}},
+			from: jan, to: month(2025, time.December),
+			want: 0,
+		},
+		{
+			name: "yearly billing outside period",
+			sub:  Subscription{Price: 1200, StartDate: month(2024, time.March), BillingMonths: 12},
+			from: month(2025, time.April), to: month(2025, time.December),
+			want: 0,
+		},
+		{
+			name: "promotion before price change",
+			sub: Subscription{Price: 100, StartDate: jan,
+				Promotions:   []Promotion{{Months: 1, Price: 50}},
+				PriceChanges: []PriceChange{{From: month(2025, time.March), Price: 200}}},
+			from: jan, to: month(2025, time.April),
+			want: 550,
+		},
+		{
+			name: "end date limits charges",
+			sub:  Subscription{Price: 100, StartDate: jan, EndDate: ptr(month(2025, time.March))},
+			from: month(2024, time.June), to: month(2025, time.December),
+			want: 300,
+		},
+		{
+			name: "period before start",
+			sub:  Subscription{Price: 100, StartDate: jan},
+			from: month(2024, time.January), to: month(2024, time.December),
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDefaults(&tt.sub)
+			if got := tt.sub.chargeIn(tt.from, tt.to, nil); got != tt.want {
+				t.Errorf("chargeIn = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestChargeInShare проверяет, что доля участника округляется в каждом
+// месяце, а владельцу остаётся нераспределённая часть.
+func TestChargeInShare(t *testing.T) {
+	owner, member, stranger := uuid.New(), uuid.New(), uuid.New()
+	sub := Subscription{Price: 999, UserID: owner, StartDate: month(2025, time.January), BillingMonths: 1,
+		Members: []Member{{UserID: member, Share: 33}}}
+	from, to := month(2025, time.January), month(2025, time.March)
+
+	for _, tt := range []struct {
+		name string
+		user uuid.UUID
+		want int
+	}{
+		{"member", member, 3 * 330},
+		{"owner", owner, 3 * 669},
+		{"stranger", stranger, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sub.chargeIn(from, to, &tt.user); got != tt.want {
+				t.Errorf("chargeIn = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
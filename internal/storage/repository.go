@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionRepository описывает операции над подписками, от которых
+// зависят обработчики. Его реализуют Store (Postgres и SQLite) и MemoryStore.
+type SubscriptionRepository interface {
+	Create(ctx context.Context, sub *Subscription) error
+	Get(ctx context.Context, id uuid.UUID) (*Subscription, error)
+	List(ctx context.Context, filter ListFilter) ([]Subscription, error)
+	Update(ctx context.Context, sub *Subscription) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Summary(ctx context.Context, filter SummaryFilter) (int, error)
+	Forecast(ctx context.Context, filter SummaryFilter) ([]MonthlyCost, error)
+	Activity(ctx context.Context, filter SummaryFilter) ([]Activity, error)
+	ServicePrices(ctx context.Context, filter SummaryFilter) ([]ServicePrice, error)
+	Lifetime(ctx context.Context, filter SummaryFilter) (*Lifetime, error)
+	Overlapping(ctx context.Context, sub *Subscription) ([]Subscription, error)
+	Overlaps(ctx context.Context, filter OverlapFilter) ([]Overlap, error)
+	Cancel(ctx context.Context, id uuid.UUID, month time.Time) (*Subscription, error)
+	Pause(ctx context.Context, id uuid.UUID, pause Pause) (*Subscription, error)
+	Resume(ctx context.Context, id uuid.UUID, month time.Time) (*Subscription, error)
+}
+
+var (
+	_ SubscriptionRepository = (*Store)(nil)
+	_ SubscriptionRepository = (*MemoryStore)(nil)
+)
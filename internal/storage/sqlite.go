@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// В SQLite нет ILIKE, а встроенный LIKE не учитывает регистр только для
+// ASCII. Регистрируем функцию ilike(value, pattern) с той же семантикой,
+// что и в MemoryStore, чтобы фильтры по названию сервиса совпадали между
+// драйверами (в том числе для кириллицы).
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("ilike", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		value, ok1 := args[0].(string)
+		pattern, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, nil
+		}
+		return cachedPattern(pattern).MatchString(value), nil
+	})
+	// lower в SQLite тоже меняет регистр только у ASCII.
+	sqlite.MustRegisterDeterministicScalarFunction("casefold", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		value, ok := args[0].(string)
+		if !ok {
+			return args[0], nil
+		}
+		return strings.ToLower(value), nil
+	})
+}
+
+// ilike возвращает условие ILIKE для колонки и плейсхолдера с номером n.
+func (s *Store) ilike(column string, n int) string {
+	if s.dialect == database.SQLite {
+		return fmt.Sprintf("ilike(%s, $%d)", column, n)
+	}
+	return fmt.Sprintf("%s ILIKE $%d", column, n)
+}
+
+// fold возвращает выражение, приводящее строку к нижнему регистру с учётом Unicode.
+func (s *Store) fold(expr string) string {
+	if s.dialect == database.SQLite {
+		return "casefold(" + expr + ")"
+	}
+	return "lower(" + expr + ")"
+}
+
+// dateParam возвращает плейсхолдер с номером n, который Postgres должен
+// понимать как DATE даже вне сравнения с колонкой.
+func (s *Store) dateParam(n int) string {
+	if s.dialect == database.SQLite {
+		return fmt.Sprintf("$%d", n)
+	}
+	return fmt.Sprintf("CAST($%d AS DATE)", n)
+}
+
+// monthIndex возвращает выражение с порядковым номером месяца даты expr
+// (год * 12 + месяц), чтобы считать разницу в месяцах.
+func (s *Store) monthIndex(expr string) string {
+	if s.dialect == database.SQLite {
+		return fmt.Sprintf("(CAST(strftime('%%Y', %[1]s) AS INTEGER) * 12 + CAST(strftime('%%m', %[1]s) AS INTEGER))", expr)
+	}
+	return fmt.Sprintf("CAST(EXTRACT(YEAR FROM %[1]s) * 12 + EXTRACT(MONTH FROM %[1]s) AS INTEGER)", expr)
+}
+
+const patternCacheSize = 256
+
+var (
+	patternMu    sync.Mutex
+	patternCache = make(map[string]*regexp.Regexp)
+)
+
+// cachedPattern компилирует шаблон ILIKE один раз на запрос, а не на каждую строку.
+func cachedPattern(pattern string) *regexp.Regexp {
+	patternMu.Lock()
+	defer patternMu.Unlock()
+
+	if re, ok := patternCache[pattern]; ok {
+		return re
+	}
+	if len(patternCache) >= patternCacheSize {
+		clear(patternCache)
+	}
+	re := likePattern(pattern)
+	patternCache[pattern] = re
+	return re
+}
+
+// sqliteErrorKind сопоставляет расширенные коды SQLite с видами ошибок.
+func sqliteErrorKind(err error) error {
+	var liteErr *sqlite.Error
+	if !errors.As(err, &liteErr) {
+		return nil
+	}
+	switch liteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return ErrDuplicate
+	case sqlite3.SQLITE_CONSTRAINT_CHECK, sqlite3.SQLITE_CONSTRAINT_NOTNULL, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return ErrConstraint
+	}
+	switch liteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return ErrConflict
+	}
+	return nil
+}
@@ -7,31 +7,61 @@ import (
 	"strings"
 	"time"
 
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/events"
 	"github.com/google/uuid"
 )
 
-// Store управляет сохранением записей подписок.
+// Store управляет сохранением записей подписок в Postgres или SQLite.
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect database.Dialect
+	// rejectOverlaps — отклонять пересекающиеся подписки, см. RejectOverlaps.
+	rejectOverlaps bool
+	// aggregateMaxAge — насколько старые агрегаты можно отдавать, см. UseAggregates.
+	aggregateMaxAge time.Duration
 }
 
 // Subscription описывает одну запись о подписке.
 type Subscription struct {
-	ID          uuid.UUID  `json:"id"`
-	ServiceName string     `json:"service_name"`
-	Price       int        `json:"price"`
-	UserID      uuid.UUID  `json:"user_id"`
-	StartDate   time.Time  `json:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ID          uuid.UUID `json:"id"`
+	ServiceName string    `json:"service_name"`
+	// Category — необязательная категория сервиса (например, «видео»).
+	Category  *string    `json:"category,omitempty"`
+	Price     int        `json:"price"`
+	UserID    uuid.UUID  `json:"user_id"`
+	StartDate time.Time  `json:"start_date"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	// TrialMonths — число бесплатных месяцев с начала подписки.
+	TrialMonths int `json:"trial_months,omitempty"`
+	// Promotions — шаги промо-цен после пробного периода, см. PriceIn.
+	Promotions []Promotion `json:"promotions,omitempty"`
+	// PriceChanges — запланированные изменения полной цены по месяцам.
+	PriceChanges []PriceChange `json:"price_changes,omitempty"`
+	// BillingMonths — период списания в месяцах; Price списывается в первый
+	// месяц каждого периода, считая от start_date.
+	BillingMonths int `json:"billing_months"`
+	// Members — участники совместной подписки; владельцу остаётся
+	// нераспределённая доля, см. ShareOf.
+	Members []Member `json:"members,omitempty"`
+	// Pauses — приостановки в порядке начала.
+	Pauses []Pause `json:"pauses,omitempty"`
 }
 
+// subscriptionColumns — колонки подписки в порядке, который ждёт scanSubscription.
+const subscriptionColumns = `id, service_name, category, price, user_id, start_date, end_date, trial_months, billing_months, created_at`
+
 // ListFilter задаёт опциональные фильтры для списка подписок.
 type ListFilter struct {
 	UserID      *uuid.UUID
 	ServiceName *string
-	Limit       int
-	Offset      int
+	// ActiveIn оставляет подписки, действующие в месяце, который начинается с этой даты.
+	ActiveIn *time.Time
+	// EndsIn оставляет подписки, у которых этот месяц — последний оплаченный.
+	EndsIn *time.Time
+	Limit  int
+	Offset int
 }
 
 // SummaryFilter описывает параметры подсчёта суммарной стоимости.
@@ -40,43 +70,70 @@ type SummaryFilter struct {
 	PeriodEnd   time.Time
 	UserID      *uuid.UUID
 	ServiceName *string
+	// Category сравнивается с категорией подписки без учёта регистра.
+	Category *string
+	// AllowStale разрешает отвечать из материализованных агрегатов, если
+	// хранилищу разрешено их использовать и они пересчитаны не раньше
+	// заданного срока (см. Store.UseAggregates); иначе сумма считается по
+	// подпискам.
+	AllowStale bool
 }
 
-// NewStore создаёт объект Store на основе переданного sql.DB.
-func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+// NewStore создаёт объект Store на основе переданного sql.DB и его диалекта.
+func NewStore(db *sql.DB, dialect database.Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
 }
 
-// Create сохраняет запись подписки и заполняет id и created_at.
+// Create сохраняет запись подписки вместе с её приостановками и заполняет id
+// и created_at. В той же транзакции в outbox пишется событие
+// subscription.created.
 func (s *Store) Create(ctx context.Context, sub *Subscription) error {
 	if sub.ID == uuid.Nil {
 		sub.ID = uuid.New()
 	}
-
-	var createdAt time.Time
-	err := s.db.QueryRowContext(ctx,
-		`INSERT INTO subscriptions (id, service_name, price, user_id, start_date, end_date)
-VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at`,
-		sub.ID, sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate,
-	).Scan(&createdAt)
-	if err != nil {
+	setDefaults(sub)
+	if err := checkInitialPauses(sub); err != nil {
 		return err
 	}
 
-	sub.CreatedAt = createdAt
-	return nil
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := s.checkOverlaps(ctx, tx, sub); err != nil {
+			return err
+		}
+		var createdAt time.Time
+		err := tx.QueryRowContext(ctx, s.dialect.Rebind(
+			`INSERT INTO subscriptions (id, service_name, category, price, user_id, start_date, end_date, trial_months, billing_months)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING created_at`),
+			sub.ID, sub.ServiceName, sub.Category, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.TrialMonths, sub.BillingMonths,
+		).Scan(&createdAt)
+		if err != nil {
+			return err
+		}
+		if err := s.saveDetails(ctx, tx, sub); err != nil {
+			return err
+		}
+		for _, pause := range sub.Pauses {
+			if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+				`INSERT INTO subscription_pauses (subscription_id, start_month, end_month, created_at) VALUES ($1, $2, $3, $4)`),
+				sub.ID, pause.From, pause.Until, createdAt); err != nil {
+				return err
+			}
+		}
+
+		sub.CreatedAt = createdAt
+		return s.insertEvent(ctx, tx, events.SubscriptionCreated, sub)
+	})
 }
 
 // Get загружает подписку по id.
 func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Subscription, error) {
-	row := s.db.QueryRowContext(ctx,
-		`SELECT id, service_name, price, user_id, start_date, end_date, created_at
-FROM subscriptions WHERE id = $1`, id)
+	row := s.db.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = $1 AND deleted_at IS NULL`), id)
 	sub, err := scanSubscription(row)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, err
-		}
+		return nil, translate(err)
+	}
+	if err := s.attachDetails(ctx, s.db, []*Subscription{sub}); err != nil {
 		return nil, err
 	}
 	return sub, nil
@@ -84,9 +141,9 @@ FROM subscriptions WHERE id = $1`, id)
 
 // List возвращает подписки, подходящие под фильтры.
 func (s *Store) List(ctx context.Context, filter ListFilter) ([]Subscription, error) {
-	query := `SELECT id, service_name, price, user_id, start_date, end_date, created_at FROM subscriptions`
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions`
 	args := make([]any, 0, 4)
-	clauses := make([]string, 0, 2)
+	clauses := []string{"deleted_at IS NULL"}
 
 	if filter.UserID != nil {
 		args = append(args, *filter.UserID)
@@ -94,13 +151,19 @@ func (s *Store) List(ctx context.Context, filter ListFilter) ([]Subscription, er
 	}
 	if filter.ServiceName != nil {
 		args = append(args, *filter.ServiceName)
-		clauses = append(clauses, fmt.Sprintf("service_name ILIKE $%d", len(args)))
+		clauses = append(clauses, s.ilike("service_name", len(args)))
 	}
-
-	if len(clauses) > 0 {
-		query += " WHERE " + strings.Join(clauses, " AND ")
+	if filter.ActiveIn != nil {
+		args = append(args, *filter.ActiveIn)
+		clauses = append(clauses, fmt.Sprintf("start_date <= $%d AND (end_date IS NULL OR end_date >= $%d)", len(args), len(args)))
+	}
+	if filter.EndsIn != nil {
+		args = append(args, *filter.EndsIn)
+		clauses = append(clauses, fmt.Sprintf("end_date = $%d", len(args)))
 	}
 
+	query += " WHERE " + strings.Join(clauses, " AND ")
+
 	query += " ORDER BY created_at DESC"
 
 	if filter.Limit > 0 {
@@ -112,99 +175,202 @@ func (s *Store) List(ctx context.Context, filter ListFilter) ([]Subscription, er
 		query += fmt.Sprintf(" OFFSET $%d", len(args))
 	}
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return s.query(ctx, query, args...)
+}
 
-	result := make([]Subscription, 0)
-	for rows.Next() {
-		sub, err := scanSubscription(rows)
+// Update обновляет существующую запись подписки и заполняет created_at.
+// В той же транзакции в outbox пишется событие subscription.updated.
+// Отсутствие подписки проверяется раньше пересечений.
+func (s *Store) Update(ctx context.Context, sub *Subscription) error {
+	setDefaults(sub)
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if s.rejectOverlaps {
+			if _, err := s.lockSubscription(ctx, tx, sub.ID); err != nil {
+				return err
+			}
+			if err := s.checkOverlaps(ctx, tx, sub); err != nil {
+				return err
+			}
+		}
+		err := tx.QueryRowContext(ctx, s.dialect.Rebind(
+			`UPDATE subscriptions SET service_name = $1, category = $2, price = $3, user_id = $4, start_date = $5, end_date = $6,
+trial_months = $7, billing_months = $8
+WHERE id = $9 AND deleted_at IS NULL RETURNING created_at`),
+			sub.ServiceName, sub.Category, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.TrialMonths, sub.BillingMonths, sub.ID,
+		).Scan(&sub.CreatedAt)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		result = append(result, *sub)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
+		if err := s.saveDetails(ctx, tx, sub); err != nil {
+			return err
+		}
+		// Приостановки меняются только через Pause и Resume.
+		current := Subscription{ID: sub.ID}
+		if err := s.attachDetails(ctx, tx, []*Subscription{&current}); err != nil {
+			return err
+		}
+		sub.Pauses = current.Pauses
 
-	return result, nil
+		return s.insertEvent(ctx, tx, events.SubscriptionUpdated, sub)
+	})
 }
 
-// Update обновляет существующую запись подписки.
-func (s *Store) Update(ctx context.Context, sub *Subscription) error {
-	res, err := s.db.ExecContext(ctx,
-		`UPDATE subscriptions SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5 WHERE id = $6`,
-		sub.ServiceName, sub.Price, sub.UserID, sub.StartDate, sub.EndDate, sub.ID,
-	)
+// Delete помечает запись подписки удалённой; физически её убирает Purge.
+// В той же транзакции в outbox пишется событие subscription.deleted.
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`UPDATE subscriptions SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`), id, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return s.insertEvent(ctx, tx, events.SubscriptionDeleted, DeletedSubscription{ID: id})
+	})
+}
+
+// Purge физически удаляет подписки, помеченные удалёнными раньше before.
+func (s *Store) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < $1`), before.UTC())
 	if err != nil {
-		return err
+		return 0, translate(err)
+	}
+	return res.RowsAffected()
+}
+
+// Summary считает начисления по подпискам за период: каждый месяц периода
+// внутри срока подписки, который не приостановлен и на который выпадает
+// списание по billing_months, добавляет цену этого месяца (с учётом
+// пробного периода, промо-цен и смен цены). С фильтром по пользователю
+// учитываются и совместные подписки, где он участник, но только в размере
+// его доли. Сумма считается в базе; с AllowStale она берётся из агрегатов,
+// если они покрывают период.
+func (s *Store) Summary(ctx context.Context, filter SummaryFilter) (int, error) {
+	if filter.AllowStale {
+		if total, ok, err := s.aggregatedSummary(ctx, filter); err != nil || ok {
+			return total, err
+		}
 	}
-	rows, err := res.RowsAffected()
+	charges, err := s.monthlyCharges(ctx, filter)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if rows == 0 {
-		return sql.ErrNoRows
+	total := 0
+	for _, m := range charges {
+		total += m.Total
 	}
-
-	return nil
+	return total, nil
 }
 
-// Delete удаляет запись подписки по идентификатору.
-func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
-	res, err := s.db.ExecContext(ctx, `DELETE FROM subscriptions WHERE id = $1`, id)
-	if err != nil {
-		return err
-	}
-	rows, err := res.RowsAffected()
+// Forecast прогнозирует расходы по месяцам периода фильтра: каждый месяц
+// считается так же, как Summary за один этот месяц, с учётом даты окончания,
+// запланированных смен цены и интервала оплаты.
+func (s *Store) Forecast(ctx context.Context, filter SummaryFilter) ([]MonthlyCost, error) {
+	charges, err := s.monthlyCharges(ctx, filter)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if rows == 0 {
-		return sql.ErrNoRows
+	result := make([]MonthlyCost, 0)
+	for month := monthOf(filter.PeriodStart); !month.After(filter.PeriodEnd); month = month.AddDate(0, 1, 0) {
+		cost := charges[monthNumber(month)]
+		cost.Month = month
+		result = append(result, cost)
 	}
-
-	return nil
+	return result, nil
 }
 
-// Summary считает суммарную цену подписок, пересекающихся с периодом.
-func (s *Store) Summary(ctx context.Context, filter SummaryFilter) (int, error) {
+// summaryWhere возвращает условие WHERE по периоду и фильтрам Summary для
+// таблицы subscriptions и его аргументы: $1 — конец периода, $2 — начало.
+func (s *Store) summaryWhere(filter SummaryFilter) (string, []any) {
 	args := []any{filter.PeriodEnd, filter.PeriodStart}
-	query := `SELECT COALESCE(SUM(price), 0) FROM subscriptions WHERE start_date <= $1 AND (end_date IS NULL OR end_date >= $2)`
+	where := `deleted_at IS NULL AND start_date <= $1 AND (end_date IS NULL OR end_date >= $2)`
 
 	if filter.UserID != nil {
 		args = append(args, *filter.UserID)
-		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+		where += fmt.Sprintf(" AND (user_id = $%[1]d OR id IN (SELECT subscription_id FROM subscription_members WHERE user_id = $%[1]d))", len(args))
 	}
 	if filter.ServiceName != nil {
 		args = append(args, *filter.ServiceName)
-		query += fmt.Sprintf(" AND service_name ILIKE $%d", len(args))
+		where += " AND " + s.ilike("service_name", len(args))
+	}
+	if filter.Category != nil {
+		args = append(args, *filter.Category)
+		where += fmt.Sprintf(" AND %s = %s", s.fold("category"), s.fold(fmt.Sprintf("CAST($%d AS TEXT)", len(args))))
 	}
+	return where, args
+}
 
-	var total int
-	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
-		return 0, err
+// MonthlyCost — прогноз расходов за один месяц.
+type MonthlyCost struct {
+	Month time.Time
+	Total int
+	// Subscriptions — число подписок со списанием в этом месяце.
+	Subscriptions int
+}
+
+// query выполняет запрос, выбирающий subscriptionColumns, и загружает связанные данные.
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]Subscription, error) {
+	return s.queryWith(ctx, s.db, query, args...)
+}
+
+// queryWith — query через заданное соединение или транзакцию q.
+func (s *Store) queryWith(ctx context.Context, q queryer, query string, args ...any) ([]Subscription, error) {
+	rows, err := q.QueryContext(ctx, s.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, translate(err)
 	}
+	defer rows.Close()
 
-	return total, nil
+	result := make([]Subscription, 0)
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, translate(err)
+	}
+
+	subs := make([]*Subscription, 0, len(result))
+	for i := range result {
+		subs = append(subs, &result[i])
+	}
+	if err := s.attachDetails(ctx, q, subs); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// scanSubscription собирает модель из результата запроса.
+// scanSubscription собирает модель из результата запроса subscriptionColumns.
 func scanSubscription(scanner interface {
 	Scan(dest ...any) error
 }) (*Subscription, error) {
 	var sub Subscription
 	var endDate sql.NullTime
-	if err := scanner.Scan(
-		&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &endDate, &sub.CreatedAt,
-	); err != nil {
+	if err := scanner.Scan(subscriptionDest(&sub, &endDate)...); err != nil {
 		return nil, err
 	}
+	setEndDate(&sub, endDate)
+	return &sub, nil
+}
+
+// subscriptionDest возвращает адреса для сканирования subscriptionColumns.
+func subscriptionDest(sub *Subscription, endDate *sql.NullTime) []any {
+	return []any{&sub.ID, &sub.ServiceName, &sub.Category, &sub.Price, &sub.UserID, &sub.StartDate, endDate, &sub.TrialMonths, &sub.BillingMonths, &sub.CreatedAt}
+}
+
+func setEndDate(sub *Subscription, endDate sql.NullTime) {
 	if endDate.Valid {
 		sub.EndDate = &endDate.Time
 	}
-	return &sub, nil
 }
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/config"
+	"github.com/BaikalMine/em-subscription-service/internal/database"
+	"github.com/BaikalMine/em-subscription-service/internal/migrate"
+	"github.com/BaikalMine/em-subscription-service/migrations"
+	"github.com/google/uuid"
+)
+
+// month возвращает первое число месяца в UTC; общий помощник тестов пакета.
+func month(year int, m time.Month) time.Time {
+	return time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func newSQLiteStore(t *testing.T) *Store {
+	t.Helper()
+	ctx := context.Background()
+	cfg := &config.Config{DBDriver: config.DriverSQLite, SQLitePath: filepath.Join(t.TempDir(), "test.db")}
+	db, dialect, err := database.Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	source, err := migrations.For(cfg.DBDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.New(db, dialect, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewStore(db, dialect)
+}
+
+// seedStores создаёт одинаковые подписки с пробным периодом, промо-ценами,
+// сменами цены, интервалом оплаты, паузами и долями в SQLite-хранилище и в
+// памяти и возвращает фильтры Summary для сравнения.
+func seedStores(t *testing.T) (*Store, *MemoryStore, map[string]SummaryFilter) {
+	t.Helper()
+	ctx := context.Background()
+	owner, member := uuid.New(), uuid.New()
+	end := month(2025, time.December)
+	video := "video"
+	subs := []Subscription{
+		{ServiceName: "Plain", Price: 400, UserID: owner, StartDate: month(2025, time.January)},
+		{ServiceName: "Trial", Price: 300, UserID: owner, StartDate: month(2025, time.February), TrialMonths: 2,
+			Promotions: []Promotion{{Months: 2, Price: 100}, {Months: 1, Price: 150}}},
+		{ServiceName: "Yearly", Price: 1200, UserID: owner, StartDate: month(2024, time.November), BillingMonths: 3,
+			PriceChanges: []PriceChange{{From: month(2025, time.May), Price: 1500}}},
+		{ServiceName: "Shared", Category: &video, Price: 999, UserID: owner, StartDate: month(2025, time.March), EndDate: &end,
+			Members: []Member{{UserID: member, Share: 33}}, TrialMonths: 1},
+		{ServiceName: "Other", Price: 250, UserID: member, StartDate: month(2025, time.June)},
+	}
+	pauses := map[string]Pause{
+		"Plain":  {From: month(2025, time.March), Until: ptr(month(2025, time.April))},
+		"Yearly": {From: month(2025, time.February), Until: ptr(month(2025, time.February))},
+		"Shared": {From: month(2025, time.October)},
+	}
+
+	sqlStore, memStore := newSQLiteStore(t), NewMemoryStore()
+	for _, repo := range []SubscriptionRepository{sqlStore, memStore} {
+		for _, sub := range subs {
+			sub := sub
+			if err := repo.Create(ctx, &sub); err != nil {
+				t.Fatalf("create %s: %v", sub.ServiceName, err)
+			}
+			if pause, ok := pauses[sub.ServiceName]; ok {
+				if _, err := repo.Pause(ctx, sub.ID, pause); err != nil {
+					t.Fatalf("pause %s: %v", sub.ServiceName, err)
+				}
+			}
+		}
+	}
+
+	filters := map[string]SummaryFilter{
+		"year":          {PeriodStart: month(2025, time.January), PeriodEnd: month(2025, time.December)},
+		"single month":  {PeriodStart: month(2025, time.May), PeriodEnd: month(2025, time.May).AddDate(0, 1, -1)},
+		"owner":         {PeriodStart: month(2025, time.January), PeriodEnd: month(2026, time.March), UserID: &owner},
+		"member":        {PeriodStart: month(2025, time.January), PeriodEnd: month(2026, time.March), UserID: &member},
+		"category":      {PeriodStart: month(2025, time.January), PeriodEnd: month(2025, time.December), Category: ptr("VIDEO")},
+		"service":       {PeriodStart: month(2025, time.January), PeriodEnd: month(2025, time.December), ServiceName: ptr("tri%")},
+		"before start":  {PeriodStart: month(2023, time.January), PeriodEnd: month(2023, time.December)},
+		"across a year": {PeriodStart: month(2024, time.November), PeriodEnd: month(2026, time.February)},
+	}
+	return sqlStore, memStore, filters
+}
+
+// TestSummaryMatchesMemoryStore проверяет, что SQL-расчёт Summary и Forecast
+// в Store совпадает с расчётом MemoryStore.
+func TestSummaryMatchesMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	sqlStore, memStore, filters := seedStores(t)
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			want, err := memStore.Summary(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := sqlStore.Summary(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("Summary = %d, want %d", got, want)
+			}
+
+			wantForecast, err := memStore.Forecast(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotForecast, err := sqlStore.Forecast(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gotForecast) != len(wantForecast) {
+				t.Fatalf("Forecast has %d months, want %d", len(gotForecast), len(wantForecast))
+			}
+			for i := range wantForecast {
+				if gotForecast[i] != wantForecast[i] {
+					t.Errorf("Forecast[%d] = %+v, want %+v", i, gotForecast[i], wantForecast[i])
+				}
+			}
+		})
+	}
+}
+
+// TestAggregatesMatchSummary проверяет, что Summary и Activity по агрегатам
+// совпадают с расчётом по подпискам.
+func TestAggregatesMatchSummary(t *testing.T) {
+	ctx := context.Background()
+	sqlStore, _, filters := seedStores(t)
+	sqlStore.UseAggregates(time.Hour)
+	if err := sqlStore.RefreshAggregates(ctx, month(2023, time.January), month(2026, time.December)); err != nil {
+		t.Fatalf("refresh aggregates: %v", err)
+	}
+	// Повторный пересчёт с меньшим окном заменяет строки, а не дублирует их.
+	if err := sqlStore.RefreshAggregates(ctx, month(2023, time.January), month(2026, time.June)); err != nil {
+		t.Fatalf("refresh aggregates: %v", err)
+	}
+
+	for name, filter := range filters {
+		t.Run(name, func(t *testing.T) {
+			want, err := sqlStore.Summary(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stale := filter
+			stale.AllowStale = true
+			got, err := sqlStore.Summary(ctx, stale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("aggregated Summary = %d, want %d", got, want)
+			}
+
+			wantActivity, err := sqlStore.Activity(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotActivity, err := sqlStore.Activity(ctx, stale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gotActivity) != len(wantActivity) {
+				t.Fatalf("aggregated Activity has %d months, want %d", len(gotActivity), len(wantActivity))
+			}
+			for i := range wantActivity {
+				if !gotActivity[i].Month.Equal(wantActivity[i].Month) || gotActivity[i].Active != wantActivity[i].Active ||
+					gotActivity[i].New != wantActivity[i].New || gotActivity[i].Ended != wantActivity[i].Ended {
+					t.Errorf("aggregated Activity[%d] = %+v, want %+v", i, gotActivity[i], wantActivity[i])
+				}
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// TestAggregatesMaxAge проверяет, что агрегаты отдаются только хранилищу,
+// которому они разрешены, и только пока не устарели.
+func TestAggregatesMaxAge(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	user := uuid.New()
+	jan := month(2025, time.January)
+	if err := store.Create(ctx, &Subscription{ServiceName: "A", Price: 100, UserID: user, StartDate: jan}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RefreshAggregates(ctx, jan, jan); err != nil {
+		t.Fatalf("refresh aggregates: %v", err)
+	}
+	// Подписка после пересчёта видна только при расчёте по подпискам.
+	if err := store.Create(ctx, &Subscription{ServiceName: "B", Price: 50, UserID: user, StartDate: jan}); err != nil {
+		t.Fatal(err)
+	}
+	filter := SummaryFilter{PeriodStart: jan, PeriodEnd: jan, AllowStale: true}
+
+	for _, tt := range []struct {
+		name   string
+		maxAge time.Duration
+		want   int
+	}{
+		{"disabled", 0, 150},
+		{"fresh", time.Hour, 100},
+		{"expired", time.Nanosecond, 150},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store.UseAggregates(tt.maxAge)
+			got, err := store.Summary(ctx, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Summary = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// repository открывает дочерний спан на каждую операцию хранилища.
+type repository struct {
+	next   storage.SubscriptionRepository
+	tracer trace.Tracer
+}
+
+// Repository оборачивает repo внутренними спанами store.<операция>. Спаны
+// самих SQL-запросов с db.operation.name и db.system.name открывает драйвер
+// базы (см. database.Open) внутри них, так что видно, какие запросы
+// выполнила операция.
+func Repository(repo storage.SubscriptionRepository) storage.SubscriptionRepository {
+	return &repository{next: repo, tracer: otel.Tracer(instrumentation)}
+}
+
+func (r *repository) Create(ctx context.Context, sub *storage.Subscription) (err error) {
+	ctx, end := r.start(ctx, "create")
+	defer func() { end(err) }()
+	return r.next.Create(ctx, sub)
+}
+
+func (r *repository) Get(ctx context.Context, id uuid.UUID) (_ *storage.Subscription, err error) {
+	ctx, end := r.start(ctx, "get")
+	defer func() { end(err) }()
+	return r.next.Get(ctx, id)
+}
+
+func (r *repository) List(ctx context.Context, filter storage.ListFilter) (_ []storage.Subscription, err error) {
+	ctx, end := r.start(ctx, "list")
+	defer func() { end(err) }()
+	return r.next.List(ctx, filter)
+}
+
+func (r *repository) Update(ctx context.Context, sub *storage.Subscription) (err error) {
+	ctx, end := r.start(ctx, "update")
+	defer func() { end(err) }()
+	return r.next.Update(ctx, sub)
+}
+
+func (r *repository) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, end := r.start(ctx, "delete")
+	defer func() { end(err) }()
+	return r.next.Delete(ctx, id)
+}
+
+func (r *repository) Summary(ctx context.Context, filter storage.SummaryFilter) (_ int, err error) {
+	ctx, end := r.start(ctx, "summary")
+	defer func() { end(err) }()
+	return r.next.Summary(ctx, filter)
+}
+
+func (r *repository) Forecast(ctx context.Context, filter storage.SummaryFilter) (_ []storage.MonthlyCost, err error) {
+	ctx, end := r.start(ctx, "forecast")
+	defer func() { end(err) }()
+	return r.next.Forecast(ctx, filter)
+}
+
+func (r *repository) Activity(ctx context.Context, filter storage.SummaryFilter) (_ []storage.Activity, err error) {
+	ctx, end := r.start(ctx, "activity")
+	defer func() { end(err) }()
+	return r.next.Activity(ctx, filter)
+}
+
+func (r *repository) ServicePrices(ctx context.Context, filter storage.SummaryFilter) (_ []storage.ServicePrice, err error) {
+	ctx, end := r.start(ctx, "service_prices")
+	defer func() { end(err) }()
+	return r.next.ServicePrices(ctx, filter)
+}
+
+func (r *repository) Lifetime(ctx context.Context, filter storage.SummaryFilter) (_ *storage.Lifetime, err error) {
+	ctx, end := r.start(ctx, "lifetime")
+	defer func() { end(err) }()
+	return r.next.Lifetime(ctx, filter)
+}
+
+func (r *repository) Overlapping(ctx context.Context, sub *storage.Subscription) (_ []storage.Subscription, err error) {
+	ctx, end := r.start(ctx, "overlapping")
+	defer func() { end(err) }()
+	return r.next.Overlapping(ctx, sub)
+}
+
+func (r *repository) Overlaps(ctx context.Context, filter storage.OverlapFilter) (_ []storage.Overlap, err error) {
+	ctx, end := r.start(ctx, "overlaps")
+	defer func() { end(err) }()
+	return r.next.Overlaps(ctx, filter)
+}
+
+func (r *repository) Cancel(ctx context.Context, id uuid.UUID, month time.Time) (_ *storage.Subscription, err error) {
+	ctx, end := r.start(ctx, "cancel")
+	defer func() { end(err) }()
+	return r.next.Cancel(ctx, id, month)
+}
+
+func (r *repository) Pause(ctx context.Context, id uuid.UUID, pause storage.Pause) (_ *storage.Subscription, err error) {
+	ctx, end := r.start(ctx, "pause")
+	defer func() { end(err) }()
+	return r.next.Pause(ctx, id, pause)
+}
+
+func (r *repository) Resume(ctx context.Context, id uuid.UUID, month time.Time) (_ *storage.Subscription, err error) {
+	ctx, end := r.start(ctx, "resume")
+	defer func() { end(err) }()
+	return r.next.Resume(ctx, id, month)
+}
+
+// start открывает спан операции и возвращает функцию, которая закрывает его
+// с ошибкой операции. Отсутствие записи ошибкой спана не считается.
+func (r *repository) start(ctx context.Context, operation string) (context.Context, func(error)) {
+	ctx, span := r.tracer.Start(ctx, "store."+operation, trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx, func(err error) {
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
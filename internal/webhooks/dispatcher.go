@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	batchSize  = 50
+	maxBackoff = 6 * time.Hour
+)
+
+// DispatcherConfig задаёт параметры доставки.
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+}
+
+// Dispatcher забирает доставки из очереди и отправляет их получателям.
+// Неудачные попытки повторяются с экспоненциальной задержкой, после
+// MaxAttempts доставка переводится в статус dead.
+type Dispatcher struct {
+	store  *Store
+	client *http.Client
+	logger *logrus.Logger
+	cfg    DispatcherConfig
+}
+
+// NewDispatcher создаёт диспетчер доставок.
+func NewDispatcher(store *Store, logger *logrus.Logger, cfg DispatcherConfig) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Run обрабатывает очередь каждые PollInterval, пока не отменён ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			processed, err := d.RunOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				d.logger.WithError(err).Error("webhook dispatch failed")
+			}
+			// Полная пачка означает, что в очереди, скорее всего, есть ещё работа.
+			if err != nil || processed < batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimMargin — запас аренды сверх времени на отправку всей пачки.
+const claimMargin = time.Minute
+
+// RunOnce отправляет одну пачку доставок и возвращает их количество.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	// Пачка отправляется по одной доставке, поэтому аренда перекрывает таймауты
+	// запросов всей пачки, иначе другой диспетчер возьмёт её хвост повторно.
+	tasks, err := d.store.claim(ctx, batchSize, batchSize*d.cfg.Timeout+claimMargin)
+	if err != nil {
+		return 0, err
+	}
+	for i := range tasks {
+		if ctx.Err() != nil {
+			// При остановке неотправленные доставки возвращаются в очередь сразу.
+			d.release(ctx, &tasks[i])
+			continue
+		}
+		d.deliver(ctx, &tasks[i])
+	}
+	return len(tasks), nil
+}
+
+// deliver отправляет одну доставку и записывает результат.
+func (d *Dispatcher) deliver(ctx context.Context, t *task) {
+	attempts := t.Attempts + 1
+	log := d.logger.WithFields(logrus.Fields{
+		"delivery_id": t.ID,
+		"webhook_id":  t.WebhookID,
+		"event_type":  t.EventType,
+		"attempt":     attempts,
+	})
+
+	statusCode, err := d.send(ctx, t)
+	if err != nil && ctx.Err() != nil {
+		// Запрос прерван остановкой, а не получателем: попытку не засчитываем.
+		d.release(ctx, t)
+		return
+	}
+	// Результат пишем даже при отмене ctx, иначе попытка потеряется до конца аренды.
+	saveCtx := context.WithoutCancel(ctx)
+	if err == nil {
+		if err := d.store.markDelivered(saveCtx, t, attempts, statusCode); err != nil {
+			log.WithError(err).Error("failed to mark webhook delivered")
+		}
+		return
+	}
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	dead := attempts >= d.cfg.MaxAttempts
+	next := time.Now().Add(d.backoff(attempts))
+	if err := d.store.markFailed(saveCtx, t, attempts, code, err.Error(), next, dead); err != nil {
+		log.WithError(err).Error("failed to record webhook failure")
+		return
+	}
+	if dead {
+		log.WithError(err).Warn("webhook delivery moved to dead letter")
+		return
+	}
+	log.WithError(err).WithField("next_attempt_at", next).Info("webhook delivery failed, will retry")
+}
+
+// release возвращает доставку в очередь без записи попытки.
+func (d *Dispatcher) release(ctx context.Context, t *task) {
+	if err := d.store.release(context.WithoutCancel(ctx), t); err != nil {
+		d.logger.WithError(err).WithField("delivery_id", t.ID).Error("failed to release webhook delivery")
+	}
+}
+
+// send выполняет HTTP-запрос с подписанным телом.
+func (d *Dispatcher) send(ctx context.Context, t *task) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(t.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, t.EventType)
+	req.Header.Set(HeaderDelivery, t.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(t.Secret, timestamp, t.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff возвращает задержку перед следующей попыткой: base * 2^(attempts-1).
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,352 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BaikalMine/em-subscription-service/internal/events"
+	"github.com/BaikalMine/em-subscription-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Статусы доставки.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// AllEvents в списке типов подписывает вебхук на все события.
+const AllEvents = "*"
+
+// Webhook — зарегистрированный получатель событий.
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Delivery — попытка доставить одно событие одному вебхуку.
+type Delivery struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      *string         `json:"last_error,omitempty"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// DeliveryFilter задаёт фильтры для списка доставок.
+type DeliveryFilter struct {
+	WebhookID uuid.UUID
+	Status    *string
+	Limit     int
+	Offset    int
+}
+
+// task — доставка, взятая в работу, вместе с адресом и секретом вебхука.
+type task struct {
+	Delivery
+	URL    string
+	Secret string
+	// Claim — метка аренды, с которой доставку взял этот диспетчер.
+	Claim uuid.UUID
+}
+
+// errClaimLost — доставку уже взял другой диспетчер: аренда истекла.
+var errClaimLost = errors.New("delivery claim lost")
+
+// Store хранит вебхуки и очередь доставок в Postgres. Ошибки базы
+// переводятся через storage.Translate: отсутствие записи сопоставляется с
+// storage.ErrNotFound.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore создаёт Store на основе переданного sql.DB.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Create регистрирует вебхук и заполняет id и created_at.
+func (s *Store) Create(ctx context.Context, hook *Webhook) error {
+	if hook.ID == uuid.Nil {
+		hook.ID = uuid.New()
+	}
+	return storage.Translate(s.db.QueryRowContext(ctx,
+		`INSERT INTO webhooks (id, url, secret, event_types, active) VALUES ($1, $2, $3, $4, $5) RETURNING created_at`,
+		hook.ID, hook.URL, hook.Secret, pq.Array(hook.EventTypes), hook.Active,
+	).Scan(&hook.CreatedAt))
+}
+
+// Get загружает вебхук по id.
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, url, secret, event_types, active, created_at FROM webhooks WHERE id = $1`, id)
+	hook, err := scanWebhook(row)
+	return hook, storage.Translate(err)
+}
+
+// List возвращает все зарегистрированные вебхуки.
+func (s *Store) List(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, url, secret, event_types, active, created_at FROM webhooks ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, storage.Translate(err)
+	}
+	defer rows.Close()
+
+	result := make([]Webhook, 0)
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, storage.Translate(err)
+		}
+		result = append(result, *hook)
+	}
+	return result, storage.Translate(rows.Err())
+}
+
+// Delete удаляет вебхук вместе с его доставками.
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return storage.Translate(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return storage.Translate(err)
+	}
+	if rows == 0 {
+		return storage.Translate(sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Publish ставит событие в очередь доставки всем активным вебхукам,
+// подписанным на его тип. Повторная публикация того же события игнорируется.
+func (s *Store) Publish(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload)
+SELECT gen_random_uuid(), id, $1, $2, $3 FROM webhooks
+WHERE active AND ($2 = ANY(event_types) OR $4 = ANY(event_types))
+ON CONFLICT (webhook_id, event_id) DO NOTHING`,
+		event.ID, event.Type, string(payload), AllEvents,
+	)
+	return storage.Translate(err)
+}
+
+// GetDelivery загружает доставку по id.
+func (s *Store) GetDelivery(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id)
+	d, err := scanDelivery(row)
+	return d, storage.Translate(err)
+}
+
+// ListDeliveries возвращает доставки вебхука, новые первыми.
+func (s *Store) ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]Delivery, error) {
+	args := []any{filter.WebhookID}
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1`
+	if filter.Status != nil {
+		args = append(args, *filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	query += " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, storage.Translate(err)
+	}
+	defer rows.Close()
+
+	result := make([]Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, storage.Translate(err)
+		}
+		result = append(result, *d)
+	}
+	return result, storage.Translate(rows.Err())
+}
+
+// Replay возвращает доставку в очередь с новым бюджетом попыток.
+func (s *Store) Replay(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	row := s.db.QueryRowContext(ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = 0, next_attempt_at = now(), delivered_at = NULL
+WHERE id = $1 RETURNING `+deliveryColumns, id, StatusPending)
+	d, err := scanDelivery(row)
+	return d, storage.Translate(err)
+}
+
+// claim забирает до limit доставок, которым пора уходить, откладывает их на
+// lease, чтобы параллельные диспетчеры не взяли их повторно, и помечает
+// новой меткой аренды; результат попытки записывается только с ней.
+func (s *Store) claim(ctx context.Context, limit int, lease time.Duration) ([]task, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, w.url, w.secret
+FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.status = $1 AND d.next_attempt_at <= now()
+ORDER BY d.next_attempt_at
+LIMIT $2
+FOR UPDATE OF d SKIP LOCKED`, StatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []task
+	ids := make([]uuid.UUID, 0, limit)
+	for rows.Next() {
+		var t task
+		if err := rows.Scan(&t.ID, &t.WebhookID, &t.EventID, &t.EventType, &t.Payload, &t.Attempts, &t.URL, &t.Secret); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tasks = append(tasks, t)
+		ids = append(ids, t.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	token := uuid.New()
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = $2, claim_token = $3 WHERE id = ANY($1::uuid[])`,
+		pq.Array(uuidStrings(ids)), time.Now().Add(lease), token,
+	); err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		tasks[i].Claim = token
+	}
+	return tasks, tx.Commit()
+}
+
+// markDelivered фиксирует успешную доставку, если аренда t ещё действует.
+func (s *Store) markDelivered(ctx context.Context, t *task, attempts, statusCode int) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $3, attempts = $4, last_status_code = $5, last_error = NULL, delivered_at = now(),
+claim_token = NULL
+WHERE id = $1 AND claim_token = $2`, t.ID, t.Claim, StatusDelivered, attempts, statusCode)
+	return claimed(res, err)
+}
+
+// markFailed фиксирует неудачную попытку, если аренда t ещё действует:
+// следующая в nextAttempt или dead-letter.
+func (s *Store) markFailed(ctx context.Context, t *task, attempts int, statusCode *int, cause string, nextAttempt time.Time, dead bool) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $3, attempts = $4, last_status_code = $5, last_error = $6, next_attempt_at = $7,
+claim_token = NULL
+WHERE id = $1 AND claim_token = $2`, t.ID, t.Claim, status, attempts, statusCode, cause, nextAttempt)
+	return claimed(res, err)
+}
+
+// release снимает аренду t без записи попытки, чтобы доставку сразу можно
+// было взять снова: попытки не было или она прервана остановкой.
+func (s *Store) release(ctx context.Context, t *task) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = now(), claim_token = NULL WHERE id = $1 AND claim_token = $2`,
+		t.ID, t.Claim)
+	return claimed(res, err)
+}
+
+// claimed проверяет, что UPDATE по метке аренды затронул строку.
+func claimed(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errClaimLost
+	}
+	return nil
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error, last_status_code, created_at, delivered_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanWebhook собирает модель вебхука из результата запроса.
+func scanWebhook(row scanner) (*Webhook, error) {
+	var hook Webhook
+	if err := row.Scan(&hook.ID, &hook.URL, &hook.Secret, pq.Array(&hook.EventTypes), &hook.Active, &hook.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// scanDelivery собирает модель доставки из результата запроса.
+func scanDelivery(row scanner) (*Delivery, error) {
+	var d Delivery
+	var lastError sql.NullString
+	var lastStatus sql.NullInt64
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &lastError, &lastStatus, &d.CreatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	if lastError.Valid {
+		d.LastError = &lastError.String
+	}
+	if lastStatus.Valid {
+		code := int(lastStatus.Int64)
+		d.LastStatusCode = &code
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = id.String()
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// files хранит наборы миграций вида NNNN_name.up.sql и NNNN_name.down.sql
+// для каждого поддерживаемого драйвера. Версии в наборах совпадают.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// For возвращает набор миграций для драйвера (postgres или sqlite).
+func For(driver string) (fs.FS, error) {
+	switch driver {
+	case "postgres", "sqlite":
+		return fs.Sub(files, driver)
+	default:
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+}
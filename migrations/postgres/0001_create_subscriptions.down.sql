@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_service_name;
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS reminder_log (
+    subscription_id UUID NOT NULL,
+    kind TEXT NOT NULL,
+    due_date DATE NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, kind, due_date)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    start_month DATE NOT NULL,
+    end_month DATE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, start_month),
+    CHECK (end_month IS NULL OR end_month >= start_month)
+);
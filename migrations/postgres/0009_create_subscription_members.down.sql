@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_subscription_members_user_id;
+DROP TABLE IF EXISTS subscription_members;
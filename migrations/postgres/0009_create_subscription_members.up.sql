@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS subscription_members (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    share INTEGER NOT NULL CHECK (share BETWEEN 1 AND 100),
+    PRIMARY KEY (subscription_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_members_user_id ON subscription_members (user_id);
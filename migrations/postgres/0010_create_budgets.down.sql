@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS budget_alerts;
+DROP TABLE IF EXISTS budgets;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS category;
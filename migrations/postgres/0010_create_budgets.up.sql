@@ -0,0 +1,18 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS category TEXT;
+
+CREATE TABLE IF NOT EXISTS budgets (
+    id UUID PRIMARY KEY,
+    user_id UUID,
+    category TEXT,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    thresholds TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id UUID NOT NULL REFERENCES budgets (id) ON DELETE CASCADE,
+    month DATE NOT NULL,
+    threshold INTEGER NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (budget_id, month, threshold)
+);
@@ -0,0 +1,8 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_months INTEGER NOT NULL DEFAULT 1 CHECK (billing_months BETWEEN 1 AND 12);
+
+CREATE TABLE IF NOT EXISTS subscription_price_changes (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    effective_month DATE NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (subscription_id, effective_month)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS aggregate_refreshes;
+DROP TABLE IF EXISTS subscription_aggregates;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS subscription_aggregates (
+    month DATE NOT NULL,
+    user_id UUID NOT NULL,
+    service_name TEXT NOT NULL,
+    category TEXT NOT NULL DEFAULT '',
+    is_owner BOOLEAN NOT NULL,
+    cost INTEGER NOT NULL DEFAULT 0,
+    share_cost INTEGER NOT NULL DEFAULT 0,
+    active INTEGER NOT NULL DEFAULT 0,
+    started INTEGER NOT NULL DEFAULT 0,
+    ended INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (month, user_id, service_name, category, is_owner)
+);
+
+CREATE TABLE IF NOT EXISTS aggregate_refreshes (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    from_month DATE NOT NULL,
+    to_month DATE NOT NULL,
+    refreshed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- claim_token — метка диспетчера, который взял доставку в работу; результат
+-- попытки записывается, только пока метка совпадает.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS claim_token UUID;
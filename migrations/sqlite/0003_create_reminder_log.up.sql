@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS reminder_log (
+    subscription_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    due_date DATE NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (subscription_id, kind, due_date)
+);
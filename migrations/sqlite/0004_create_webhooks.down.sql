@@ -0,0 +1,3 @@
+-- Таблицы могли остаться от прежней версии миграции, создававшей их и в SQLite.
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
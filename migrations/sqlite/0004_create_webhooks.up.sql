@@ -0,0 +1,4 @@
+-- Вебхуки доставляются только при работе с Postgres, поэтому в SQLite их
+-- таблицы не создаются. Миграция оставлена пустой, чтобы версии схемы
+-- совпадали между драйверами.
+SELECT 1;
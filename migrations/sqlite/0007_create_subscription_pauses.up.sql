@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    subscription_id TEXT NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    start_month DATE NOT NULL,
+    end_month DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (subscription_id, start_month),
+    CHECK (end_month IS NULL OR end_month >= start_month)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_promotions;
+ALTER TABLE subscriptions DROP COLUMN trial_months;
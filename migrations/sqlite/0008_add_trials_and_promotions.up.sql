@@ -0,0 +1,9 @@
+ALTER TABLE subscriptions ADD COLUMN trial_months INTEGER NOT NULL DEFAULT 0 CHECK (trial_months >= 0);
+
+CREATE TABLE IF NOT EXISTS subscription_promotions (
+    subscription_id TEXT NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    months INTEGER NOT NULL CHECK (months > 0),
+    price INTEGER NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (subscription_id, position)
+);
@@ -0,0 +1,18 @@
+ALTER TABLE subscriptions ADD COLUMN category TEXT;
+
+CREATE TABLE IF NOT EXISTS budgets (
+    id TEXT PRIMARY KEY,
+    user_id TEXT,
+    category TEXT,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    thresholds TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id TEXT NOT NULL REFERENCES budgets (id) ON DELETE CASCADE,
+    month DATE NOT NULL,
+    threshold INTEGER NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (budget_id, month, threshold)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS subscription_price_changes;
+ALTER TABLE subscriptions DROP COLUMN billing_months;
@@ -0,0 +1,3 @@
+-- Вебхуки работают только с Postgres, см. 0004. Миграция пустая, чтобы
+-- версии схемы совпадали между драйверами.
+SELECT 1;